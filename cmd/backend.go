@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ahmaruff/hfl/internal/config"
+	"github.com/ahmaruff/hfl/internal/gitignore"
+	"github.com/ahmaruff/hfl/internal/notion"
+	"github.com/ahmaruff/hfl/internal/obsidian"
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+// loadSyncContext loads everything a sync command needs, exiting with a
+// message if any of it is missing
+func loadSyncContext() (*config.Config, *hflsync.Engine, *parser.Journal, *state.State) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	backend, options := newBackend(cfg)
+
+	// Ensure .hfl/ is gitignored (before creating any .hfl files)
+	if err := gitignore.EnsureHFLIgnored(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update .gitignore: %v\n", err)
+	}
+
+	journal, warnings, err := parser.ParseFile("hfl.md")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading hfl.md: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(warnings) > 0 {
+		fmt.Println("Warnings in hfl.md:")
+		for _, warning := range warnings {
+			fmt.Println("  " + warning)
+		}
+		fmt.Println()
+	}
+
+	syncState, err := state.Load(backend.Name())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading sync state: %v\n", err)
+		os.Exit(1)
+	}
+
+	return cfg, hflsync.NewEngine(backend, options), journal, syncState
+}
+
+// newBackend builds the backend set by sync.backend and the engine options
+// that go with it, exiting with a message if it isn't configured
+func newBackend(cfg *config.Config) (hflsync.Backend, hflsync.Options) {
+	switch backend := cfg.Sync.GetBackend(); backend {
+	case "notion":
+		if cfg.Notion.ApiToken == "" {
+			fmt.Fprintf(os.Stderr, "Error: Notion API token not configured\n")
+			fmt.Fprintf(os.Stderr, "Set it with: hfl config set notion.api_token \"your-token\"\n")
+			os.Exit(1)
+		}
+
+		if cfg.Notion.DatabaseID == "" {
+			fmt.Fprintf(os.Stderr, "Error: Notion database ID not configured\n")
+			fmt.Fprintf(os.Stderr, "Set it with: hfl config set notion.database_id \"your-db-id\"\n")
+			os.Exit(1)
+		}
+
+		options := hflsync.Options{OnRemoteDelete: cfg.Notion.GetOnRemoteDelete()}
+		return notion.NewSyncService(cfg.Notion), options
+	case "obsidian":
+		if cfg.Obsidian.Vault == "" {
+			fmt.Fprintf(os.Stderr, "Error: Obsidian vault not configured\n")
+			fmt.Fprintf(os.Stderr, "Set it with: hfl config set obsidian.vault \"path/to/vault\"\n")
+			os.Exit(1)
+		}
+
+		if info, err := os.Stat(cfg.Obsidian.Vault); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: Obsidian vault %s is not a folder\n", cfg.Obsidian.Vault)
+			os.Exit(1)
+		}
+
+		return obsidian.NewFolder(cfg.Obsidian), hflsync.Options{}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown sync backend %q\n", backend)
+		os.Exit(1)
+		return nil, hflsync.Options{}
+	}
+}
+
+// backendTitle returns the name of a backend as it reads in messages
+func backendTitle(name string) string {
+	switch name {
+	case "notion":
+		return "Notion"
+	case "obsidian":
+		return "Obsidian"
+	}
+	return name
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"github.com/ahmaruff/hfl/internal/gitignore"
+	"github.com/ahmaruff/hfl/internal/merge"
 	"github.com/ahmaruff/hfl/internal/parser"
 	"github.com/spf13/cobra"
 	"os"
@@ -22,13 +23,21 @@ func runCheck(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Warning: could not update .gitignore: %v\n", err)
 	}
 
-	_, warnings, err := parser.ParseFile("hfl.md")
+	journal, warnings, err := parser.ParseFile("hfl.md")
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Report conflict markers left behind by a merge sync
+	for _, entry := range journal.Entries {
+		bodyLine := findEntryLine("hfl.md", entry.Date)
+		for _, offset := range merge.ConflictMarkerLines(entry.Body) {
+			warnings = append(warnings, fmt.Sprintf("WARN unresolved merge conflict in %s at line %d", entry.Date, bodyLine+offset))
+		}
+	}
+
 	for _, warning := range warnings {
 		fmt.Println(warning)
 	}
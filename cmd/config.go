@@ -51,20 +51,7 @@ func runConfigSet(cmd *cobra.Command, args []string) {
 		fmt.Fprintf(os.Stderr, "Warning: could not update .gitignore: %v\n", err)
 	}
 
-	// Determine which config file to load/modify
-	var configPath string
-	if globalFlag {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
-		configPath = filepath.Join(homeDir, ".hfl", "config.json")
-	} else {
-		configPath = ".hfl/config.json"
-	}
-
-	cfg, err := config.LoadFile(configPath)
+	cfg, err := loadConfigFile(globalFlag)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config file: %v\n", err)
 		os.Exit(1)
@@ -87,6 +74,21 @@ func runConfigSet(cmd *cobra.Command, args []string) {
 	fmt.Printf("Set %s config: %s = %s\n", scope, key, value)
 }
 
+// loadConfigFile loads only the local or global config file, the one
+// config.Save writes back
+func loadConfigFile(global bool) (*config.Config, error) {
+	configPath := ".hfl/config.json"
+	if global {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		configPath = filepath.Join(homeDir, ".hfl", "config.json")
+	}
+
+	return config.LoadFile(configPath)
+}
+
 func runConfigGet(cmd *cobra.Command, args []string) {
 	cfg, err := config.Load()
 	if err != nil {
@@ -119,8 +121,19 @@ func runConfigList(cmd *cobra.Command, args []string) {
 	fmt.Println()
 	fmt.Println("  editor                - Your preferred text editor")
 	fmt.Println("  conflict_strategy     - How to handle sync conflicts (remote, local, merge)")
+	fmt.Println("  history.git           - Commit hfl.md to git after edit, sync and resolve (default false)")
+	fmt.Println("  sync.backend          - Where entries are synced: notion or obsidian (default notion)")
 	fmt.Println("  notion.api_token      - Notion API token for sync")
 	fmt.Println("  notion.database_id    - Notion database ID for sync")
+	fmt.Println("  notion.rate_limit     - Max Notion requests per second (default 3)")
+	fmt.Println("  notion.max_retries    - Retries for rate-limited or failed requests (default 5, 0 disables)")
+	fmt.Println("  notion.on_remote_delete - When a page is archived in Notion: unlink, delete, or recreate")
+	fmt.Println("  notion.title_template - Page title from {date} and {sentence} (default {sentence})")
+	fmt.Println("  notion.title_length   - Max characters of {sentence} in a title (default 60)")
+	fmt.Println("  notion.properties.<field> - Database property for title, date, hfl_date, word_count or sync_status (none disables it)")
+	fmt.Println("  obsidian.vault        - Folder of daily notes for the obsidian backend")
+	fmt.Println("  obsidian.pattern      - Path of each note from {date}, {year}, {month}, {day} (default {date}.md)")
+	fmt.Println("  obsidian.frontmatter  - Add a date frontmatter block to new notes (default false)")
 	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  hfl config set editor \"code\"")
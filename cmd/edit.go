@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/ahmaruff/hfl/internal/config"
 	"github.com/ahmaruff/hfl/internal/gitignore"
+	"github.com/ahmaruff/hfl/internal/history"
 	"github.com/ahmaruff/hfl/internal/parser"
 	"github.com/ahmaruff/hfl/internal/state"
 	"github.com/ahmaruff/hfl/internal/writer"
@@ -53,6 +55,8 @@ func runEdit(cmd *cobra.Command, args []string) {
 
 	// Post-edit validation
 	validateAfterEdit()
+
+	commitHistory(nil)
 }
 
 func validateDate(date string) error {
@@ -98,8 +102,47 @@ func validateAfterEdit() {
 	fmt.Printf("File is valid. Found %d entries, formatted successfully.\n", len(journal.Entries))
 }
 
+// commitHistory commits hfl.md when history.git is on. notes describes
+// entries changed by something other than an edit; see history.Message.
+// Failures are only warned about, since hfl.md itself is already saved.
+func commitHistory(notes map[string]string) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load config: %v\n", err)
+		return
+	}
+	if !cfg.History.GetGit() {
+		return
+	}
+
+	repo, err := history.Open(".")
+	if errors.Is(err, history.ErrNotOurs) {
+		fmt.Printf("Not committing hfl.md: %v\n", err)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to open history: %v\n", err)
+		return
+	}
+
+	message, err := repo.Commit("hfl.md", notes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit hfl.md: %v\n", err)
+		return
+	}
+	if message != "" {
+		fmt.Printf("Committed hfl.md: %s\n", message)
+	}
+}
+
 func updateStateAfterEdit(journal *parser.Journal) {
-	state, err := state.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to load config: %v\n", err)
+		return
+	}
+
+	state, err := state.Load(cfg.Sync.GetBackend())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to load state: %v\n", err)
 		return
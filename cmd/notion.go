@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ahmaruff/hfl/internal/config"
+	"github.com/ahmaruff/hfl/internal/gitignore"
+	"github.com/ahmaruff/hfl/internal/notion"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var notionCmd = &cobra.Command{
+	Use:   "notion",
+	Short: "Maintain the Notion database",
+	Long:  "Commands that inspect and repair the Notion database HFL syncs with.",
+}
+
+var notionDedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Merge or archive duplicate pages for the same date",
+	Long:  "Find dates with more than one page in Notion, show the pages side by side, and merge them or keep the newest.",
+	Run:   runNotionDedupe,
+}
+
+var notionConnectCmd = &cobra.Command{
+	Use:   "connect",
+	Short: "Check the API token and choose the database to sync with",
+	Long: `Check the Notion API token, list the databases shared with the integration,
+and save the one you pick as notion.database_id. A pasted Notion URL works too,
+and --parent creates a new journal database under a page.`,
+	Run: runNotionConnect,
+}
+
+var (
+	connectToken    string
+	connectDatabase string
+	connectParent   string
+	connectTitle    string
+	connectGlobal   bool
+)
+
+// dedupeWidth is the total width of the side-by-side page listing
+const dedupeWidth = 120
+
+func runNotionDedupe(cmd *cobra.Command, args []string) {
+	_, engine, _, syncState := loadSyncContext()
+
+	syncService, ok := engine.Backend().(*notion.SyncService)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: dedupe needs sync.backend set to notion, not %s\n", engine.Backend().Name())
+		os.Exit(1)
+	}
+
+	duplicates, err := syncService.FindDuplicates()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(duplicates) == 0 {
+		fmt.Println("No duplicate pages found")
+		return
+	}
+
+	fmt.Printf("Found %d dates with duplicate pages\n", len(duplicates))
+	reader := bufio.NewReader(os.Stdin)
+
+	for _, duplicate := range duplicates {
+		headers := make([]string, len(duplicate.Pages))
+		bodies := make([]string, len(duplicate.Pages))
+		for i, page := range duplicate.Pages {
+			body, err := syncService.PageContent(page.ID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading page %s: %v\n", page.ID, err)
+				os.Exit(1)
+			}
+			headers[i] = fmt.Sprintf("%s (edited %s)", page.ID, page.LastEditedTime.Local().Format("2006-01-02 15:04"))
+			bodies[i] = body
+		}
+
+		fmt.Printf("\n%s has %d pages, newest first:\n\n", duplicate.Date, len(duplicate.Pages))
+		fmt.Print(sideBySide(headers, bodies, dedupeWidth))
+
+		answer, err := promptDedupe(reader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		switch answer {
+		case "merge":
+			_, err = syncService.MergeDuplicate(syncState, duplicate)
+		case "newest":
+			err = syncService.KeepNewest(syncState, duplicate)
+		default:
+			fmt.Printf("%s: skipped\n", duplicate.Date)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error deduplicating %s: %v\n", duplicate.Date, err)
+			os.Exit(1)
+		}
+	}
+
+	hflsync.WriteReport(os.Stdout, syncService.Report())
+}
+
+func runNotionConnect(cmd *cobra.Command, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	token := connectToken
+	if token == "" {
+		token = cfg.Notion.ApiToken
+	}
+	if token == "" {
+		fmt.Println("Create an integration at https://www.notion.so/my-integrations and copy its token.")
+		if token, err = prompt(reader, "Notion API token: "); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	client := notion.NewClientWithOptions(token, notion.ClientOptions{
+		RequestsPerSecond: cfg.Notion.RateLimit,
+		MaxRetries:        cfg.Notion.MaxRetries,
+	})
+
+	user, err := client.GetMe()
+	if notion.IsUnauthorized(err) {
+		fmt.Fprintln(os.Stderr, "Error: Notion rejected the API token. Copy it again from https://www.notion.so/my-integrations")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error checking the API token: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Connected as %s\n", user.DisplayName())
+
+	var databaseID string
+	switch {
+	case connectParent != "":
+		databaseID, err = createJournalDatabase(client, cfg, connectParent, connectTitle)
+	case connectDatabase != "":
+		databaseID, err = notion.ParseID(connectDatabase)
+	default:
+		databaseID, err = chooseDatabase(reader, client, cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	database, err := client.VerifyDatabase(databaseID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if !connectGlobal {
+		// Ensure .hfl/ is gitignored (before creating any .hfl files)
+		if err := gitignore.EnsureHFLIgnored(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not update .gitignore: %v\n", err)
+		}
+	}
+
+	fileConfig, err := loadConfigFile(connectGlobal)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config file: %v\n", err)
+		os.Exit(1)
+	}
+	fileConfig.Notion.DatabaseID = database.ID
+	if token != cfg.Notion.ApiToken {
+		fileConfig.Notion.ApiToken = token
+	}
+	if err := config.Save(fileConfig, connectGlobal); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Saved database %q (%s) as notion.database_id\n", database.PlainTitle(), database.ID)
+	fmt.Println("Run 'hfl sync' to sync your journal.")
+}
+
+// chooseDatabase lists the databases the integration can see and asks for
+// one of them, a pasted URL, or a new database
+func chooseDatabase(reader *bufio.Reader, client *notion.Client, cfg *config.Config) (string, error) {
+	databases, err := client.SearchDatabases("")
+	if err != nil {
+		return "", fmt.Errorf("failed to list databases: %w", err)
+	}
+
+	if len(databases) == 0 {
+		fmt.Println("No databases are shared with this integration yet.")
+		fmt.Println("Share one from its ••• menu → Connections, or create a new one.")
+	} else {
+		fmt.Println("Databases shared with this integration:")
+		for i, database := range databases {
+			fmt.Printf("  %d. %s (%s)\n", i+1, database.PlainTitle(), database.ID)
+		}
+	}
+
+	for {
+		answer, err := prompt(reader, "\nNumber, Notion URL or ID, or [n]ew database: ")
+		if err != nil {
+			return "", err
+		}
+
+		if answer == "n" || answer == "new" {
+			parent, err := prompt(reader, "URL of the page to create it under: ")
+			if err != nil {
+				return "", err
+			}
+			title, err := prompt(reader, fmt.Sprintf("Title [%s]: ", connectTitle))
+			if err != nil {
+				return "", err
+			}
+			if title == "" {
+				title = connectTitle
+			}
+			return createJournalDatabase(client, cfg, parent, title)
+		}
+
+		if n, err := strconv.Atoi(answer); err == nil {
+			if n >= 1 && n <= len(databases) {
+				return databases[n-1].ID, nil
+			}
+			fmt.Printf("Pick a number from 1 to %d\n", len(databases))
+			continue
+		}
+
+		id, err := notion.ParseID(answer)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		return id, nil
+	}
+}
+
+// createJournalDatabase creates a database with the properties
+// notion.properties maps under the page at parent
+func createJournalDatabase(client *notion.Client, cfg *config.Config, parent, title string) (string, error) {
+	parentID, err := notion.ParseID(parent)
+	if err != nil {
+		return "", err
+	}
+
+	database, err := client.CreateDatabase(parentID, title, notion.JournalSchema(cfg.Notion.Properties))
+	if notion.IsNotFound(err) {
+		return "", fmt.Errorf("page %s not found: share it with your integration (••• → Connections) first", parentID)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create database: %w", err)
+	}
+
+	fmt.Printf("Created database %q\n", database.PlainTitle())
+	return database.ID, nil
+}
+
+// prompt reads one trimmed line from the reader
+func prompt(reader *bufio.Reader, question string) (string, error) {
+	fmt.Print(question)
+	answer, err := reader.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("connect interrupted: %w", err)
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+func promptDedupe(reader *bufio.Reader) (string, error) {
+	for {
+		fmt.Print("\n[m]erge into the newest, keep [n]ewest and archive the rest, [s]kip? ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("dedupe interrupted: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "m", "merge":
+			return "merge", nil
+		case "n", "newest":
+			return "newest", nil
+		case "s", "skip":
+			return "skip", nil
+		}
+	}
+}
+
+// sideBySide lays texts out in columns of equal width under their headers,
+// wrapping long lines
+func sideBySide(headers, texts []string, width int) string {
+	const separator = " | "
+
+	columnWidth := (width - len(separator)*(len(texts)-1)) / len(texts)
+	if columnWidth < 20 {
+		columnWidth = 20
+	}
+
+	columns := make([][]string, len(texts))
+	rows := 0
+	for i, text := range texts {
+		columns[i] = append(wrapLines(headers[i], columnWidth), strings.Repeat("-", columnWidth))
+		columns[i] = append(columns[i], wrapLines(text, columnWidth)...)
+		if len(columns[i]) > rows {
+			rows = len(columns[i])
+		}
+	}
+
+	var builder strings.Builder
+	for row := 0; row < rows; row++ {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cell := ""
+			if row < len(column) {
+				cell = column[row]
+			}
+			cells[i] = cell + strings.Repeat(" ", columnWidth-len([]rune(cell)))
+		}
+		builder.WriteString(strings.TrimRight(strings.Join(cells, separator), " "))
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// wrapLines splits text into lines of at most width runes
+func wrapLines(text string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		runes := []rune(line)
+		for len(runes) > width {
+			lines = append(lines, string(runes[:width]))
+			runes = runes[width:]
+		}
+		lines = append(lines, string(runes))
+	}
+	return lines
+}
+
+func init() {
+	notionConnectCmd.Flags().StringVar(&connectToken, "token", "", "Notion API token (default: notion.api_token)")
+	notionConnectCmd.Flags().StringVar(&connectDatabase, "database", "", "URL or ID of the database to use")
+	notionConnectCmd.Flags().StringVar(&connectParent, "parent", "", "URL or ID of a page to create a new journal database under")
+	notionConnectCmd.Flags().StringVar(&connectTitle, "title", "Journal", "Title of a new database")
+	notionConnectCmd.Flags().BoolVar(&connectGlobal, "global", false, "Save to global config instead of local")
+
+	notionCmd.AddCommand(notionConnectCmd)
+	notionCmd.AddCommand(notionDedupeCmd)
+	RootCmd.AddCommand(notionCmd)
+}
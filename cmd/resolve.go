@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahmaruff/hfl/internal/config"
+	"github.com/ahmaruff/hfl/internal/merge"
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+	"github.com/ahmaruff/hfl/internal/writer"
+	"github.com/spf13/cobra"
+)
+
+var resolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Resolve sync conflicts one entry at a time",
+	Long:  "Walk through each conflicted date, show local and remote differences, and choose which version to keep.",
+	Run:   runResolve,
+}
+
+func runResolve(cmd *cobra.Command, args []string) {
+	cfg, engine, journal, syncState := loadSyncContext()
+
+	if _, err := engine.DetectConflicts(journal, syncState); err != nil {
+		fmt.Fprintf(os.Stderr, "Error detecting conflicts: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := resolveConflicts(engine, journal, syncState, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Keeping the remote version or editing both rewrote hfl.md
+	commitHistory(historyNotes(engine.Report(), backendTitle(engine.Backend().Name())))
+}
+
+// resolveConflicts asks how to settle each conflicted entry and applies
+// the answer straight away. Every decision is saved in state before it is
+// applied, so an interrupted session resumes where it stopped.
+func resolveConflicts(engine *hflsync.Engine, journal *parser.Journal, syncState *state.State, cfg *config.Config) error {
+	dates := syncState.Conflicts()
+	if len(dates) == 0 {
+		fmt.Println("No conflicts to resolve")
+		return nil
+	}
+
+	fmt.Printf("Resolving %d conflicts\n", len(dates))
+	reader := bufio.NewReader(os.Stdin)
+
+	for _, date := range dates {
+		entryState, _ := syncState.GetEntry(date)
+
+		resolution := entryState.Resolution
+		switch resolution {
+		case state.ResolutionLocal, state.ResolutionRemote:
+			fmt.Printf("\n%s: applying saved decision (keep %s)\n", date, resolution)
+		case state.ResolutionSkip:
+			fmt.Printf("\n%s: skipped earlier in this session\n", date)
+			continue
+		default:
+			choice, err := promptResolution(reader, engine, journal, syncState, cfg, date)
+			if err != nil {
+				return err
+			}
+			resolution = choice
+
+			syncState.Resolve(date, resolution)
+			if err := syncState.Save(); err != nil {
+				return fmt.Errorf("failed to save decision for %s: %w", date, err)
+			}
+		}
+
+		if err := applyResolution(engine, journal, syncState, date, resolution); err != nil {
+			return err
+		}
+	}
+
+	// The session finished, so skipped entries are asked about next time
+	for _, date := range syncState.Conflicts() {
+		if entryState, _ := syncState.GetEntry(date); entryState.Resolution == state.ResolutionSkip {
+			syncState.Resolve(date, "")
+		}
+	}
+
+	return syncState.Save()
+}
+
+func promptResolution(reader *bufio.Reader, engine *hflsync.Engine, journal *parser.Journal, syncState *state.State, cfg *config.Config, date string) (string, error) {
+	entryState, _ := syncState.GetEntry(date)
+
+	local := ""
+	for _, entry := range journal.Entries {
+		if entry.Date == date {
+			local = entry.Body
+		}
+	}
+
+	remote, err := engine.Content(entryState.RemoteID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote content for %s: %w", date, err)
+	}
+
+	fmt.Printf("\n%s changed locally and in %s:\n\n", date, backendTitle(engine.Backend().Name()))
+	fmt.Print(merge.UnifiedDiff(local, remote, "local/"+date, "remote/"+date))
+
+	for {
+		fmt.Print("\n[l]ocal, [r]emote, [e]dit both, [s]kip? ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("resolution interrupted: %w", err)
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "l", "local":
+			return state.ResolutionLocal, nil
+		case "r", "remote":
+			return state.ResolutionRemote, nil
+		case "s", "skip":
+			return state.ResolutionSkip, nil
+		case "e", "edit":
+			edited, ok, err := editBothVersions(cfg, syncState, date, local, remote)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				fmt.Println("Conflict markers are still present; choose again.")
+				continue
+			}
+
+			setEntryBody(journal, date, edited)
+			if err := writer.WriteFile("hfl.md", journal); err != nil {
+				return "", fmt.Errorf("failed to write hfl.md: %w", err)
+			}
+			return state.ResolutionLocal, nil
+		}
+	}
+}
+
+// editBothVersions opens a three-way merge of both versions in the editor
+// and returns the edited body. It reports false if conflict markers remain.
+func editBothVersions(cfg *config.Config, syncState *state.State, date, local, remote string) (string, bool, error) {
+	base, _ := syncState.Base(date)
+	result := merge.Merge(base, local, remote)
+
+	dir, err := os.MkdirTemp("", "hfl-resolve")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, date+".md")
+	if err := os.WriteFile(filename, []byte(result.Text+"\n"), 0644); err != nil {
+		return "", false, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	editorCmd := buildEditorCommand(cfg.GetEditor(), filename, 0)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+
+	if err := editorCmd.Run(); err != nil {
+		return "", false, fmt.Errorf("failed to run editor: %w", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read edited file: %w", err)
+	}
+
+	edited := strings.TrimRight(string(data), "\n")
+	return edited, !merge.HasConflictMarkers(edited), nil
+}
+
+// applyResolution pushes or pulls a single entry and rewrites hfl.md
+func applyResolution(engine *hflsync.Engine, journal *parser.Journal, syncState *state.State, date, resolution string) error {
+	switch resolution {
+	case state.ResolutionLocal:
+		if err := engine.PushEntry(journal, syncState, date); err != nil {
+			return err
+		}
+		fmt.Printf("%s: kept local version\n", date)
+	case state.ResolutionRemote:
+		if err := engine.PullEntry(journal, syncState, date); err != nil {
+			return err
+		}
+		if err := writer.WriteFile("hfl.md", journal); err != nil {
+			return fmt.Errorf("failed to write hfl.md: %w", err)
+		}
+		if err := syncState.Save(); err != nil {
+			return err
+		}
+		fmt.Printf("%s: kept remote version\n", date)
+	default:
+		fmt.Printf("%s: skipped\n", date)
+	}
+
+	return nil
+}
+
+func setEntryBody(journal *parser.Journal, date, body string) {
+	for i, entry := range journal.Entries {
+		if entry.Date == date {
+			journal.Entries[i].Body = body
+			return
+		}
+	}
+}
+
+func init() {
+	RootCmd.AddCommand(resolveCmd)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/ahmaruff/hfl/internal/config"
 	"github.com/ahmaruff/hfl/internal/gitignore"
 	"github.com/ahmaruff/hfl/internal/parser"
 	"github.com/ahmaruff/hfl/internal/state"
@@ -30,8 +31,14 @@ func runStatus(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Load state
-	state, err := state.Load()
+	state, err := state.Load(cfg.Sync.GetBackend())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading state: %v\n", err)
 		os.Exit(1)
@@ -41,12 +48,16 @@ func runStatus(cmd *cobra.Command, args []string) {
 	var newEntries []string
 	var modifiedEntries []string
 	var syncedEntries []string
+	var unlinkedEntries []string
 
 	for _, entry := range journal.Entries {
-		_, exists := state.GetEntry(entry.Date)
+		entryState, exists := state.GetEntry(entry.Date)
 
-		if !exists {
-			// Entry doesn't exist in state = new
+		if entryState.Unlinked {
+			// Deleted remotely; the entry is kept locally only
+			unlinkedEntries = append(unlinkedEntries, entry.Date)
+		} else if !exists || entryState.RemoteID == "" {
+			// No document yet, e.g. never pushed or its creation failed = new
 			newEntries = append(newEntries, entry.Date)
 		} else if state.HasChanged(entry.Date, entry.Body) {
 			// Entry exists but content changed = modified
@@ -76,6 +87,34 @@ func runStatus(cmd *cobra.Command, args []string) {
 		fmt.Println()
 	}
 
+	if len(state.Queue) > 0 {
+		fmt.Printf("Queued changes (%d, pushed on the next sync):\n", len(state.Queue))
+		for _, queued := range state.Queue {
+			fmt.Printf("  %-6s %s  queued %s", queued.Op, queued.Date, queued.QueuedAt)
+			if queued.Error != "" {
+				fmt.Printf(": %s", queued.Error)
+			}
+			fmt.Println()
+		}
+		fmt.Println()
+	}
+
+	if deletedEntries := state.RecordDeletions(journal); len(deletedEntries) > 0 {
+		fmt.Printf("Deleted entries (%d, delete remotely with 'hfl sync --prune'):\n", len(deletedEntries))
+		for _, date := range deletedEntries {
+			fmt.Printf("  %s\n", date)
+		}
+		fmt.Println()
+	}
+
+	if len(unlinkedEntries) > 0 {
+		fmt.Printf("Unlinked entries (%d, deleted in %s):\n", len(unlinkedEntries), backendTitle(cfg.Sync.GetBackend()))
+		for _, date := range unlinkedEntries {
+			fmt.Printf("  %s\n", date)
+		}
+		fmt.Println()
+	}
+
 	if len(syncedEntries) > 0 {
 		fmt.Printf("Synced entries (%d):\n", len(syncedEntries))
 		for _, date := range syncedEntries {
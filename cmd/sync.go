@@ -3,10 +3,10 @@ package cmd
 import (
 	"fmt"
 	"github.com/ahmaruff/hfl/internal/config"
-	"github.com/ahmaruff/hfl/internal/gitignore"
 	"github.com/ahmaruff/hfl/internal/notion"
 	"github.com/ahmaruff/hfl/internal/parser"
 	"github.com/ahmaruff/hfl/internal/state"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
 	"github.com/ahmaruff/hfl/internal/writer"
 	"github.com/spf13/cobra"
 	"os"
@@ -15,102 +15,257 @@ import (
 
 var syncCmd = &cobra.Command{
 	Use:   "sync",
-	Short: "Synchronize journal with Notion",
-	Long:  "Two-way sync between local hfl.md and Notion database.",
+	Short: "Synchronize journal with the sync backend",
+	Long:  "Two-way sync between local hfl.md and the backend set by sync.backend (Notion by default).",
 	Run:   runSync,
 }
 
 var (
-	pushOnly bool
-	pullOnly bool
-	dryRun   bool
+	pushOnly    bool
+	pullOnly    bool
+	dryRun      bool
+	interactive bool
+	prune       bool
+	resume      bool
+	relink      bool
 )
 
 func runSync(cmd *cobra.Command, args []string) {
-	cfg, err := config.Load()
+	cfg, engine, journal, syncState := loadSyncContext()
+	remote := backendTitle(engine.Backend().Name())
+
+	if syncService, ok := engine.Backend().(*notion.SyncService); ok {
+		if err := checkSchema(syncService, !dryRun); err != nil {
+			if dryRun {
+				fmt.Fprintf(os.Stderr, "Database schema validation failed: %v\n", err)
+				os.Exit(1)
+			}
+			failSync(engine, "Database schema validation failed", err)
+		}
+	}
+
+	tombstones := syncState.RecordDeletions(journal)
+
+	if dryRun {
+		fmt.Println("Dry run mode - no changes will be made")
+		showSyncPlan(journal, syncState, remote)
+		return
+	}
+
+	// With several documents for one date there's no telling which one an
+	// entry belongs to
+	duplicates, err := engine.FindDuplicates(syncState)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		failSync(engine, "Sync failed", err)
+	}
+	if len(duplicates) > 0 {
+		fmt.Fprintf(os.Stderr, "Refusing to sync: %d dates have more than one document in %s:\n", len(duplicates), remote)
+		for _, duplicate := range duplicates {
+			fmt.Fprintf(os.Stderr, "  %s (%d documents)\n", duplicate.Date, len(duplicate.IDs))
+		}
+		if _, ok := engine.Backend().(*notion.SyncService); ok {
+			fmt.Fprintln(os.Stderr, "Run 'hfl notion dedupe' to merge or archive them, then sync again.")
+		} else {
+			fmt.Fprintf(os.Stderr, "Remove all but one of each in %s, then sync again.\n", remote)
+		}
 		os.Exit(1)
 	}
 
-	if cfg.Notion.ApiToken == "" {
-		fmt.Fprintf(os.Stderr, "Error: Notion API token not configured\n")
-		fmt.Fprintf(os.Stderr, "Set it with: hfl config set notion.api_token \"your-token\"\n")
-		os.Exit(1)
+	if relink {
+		fmt.Printf("Rebuilding sync state from %s...\n", remote)
+		if err := engine.Relink(journal, syncState); err != nil {
+			fmt.Fprintf(os.Stderr, "Relink failed: %v\n", err)
+			os.Exit(1)
+		}
+		if err := syncState.Save(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving state: %v\n", err)
+			os.Exit(1)
+		}
+
+		hflsync.WriteReport(os.Stdout, engine.Report())
+		fmt.Println("State rebuilt; run 'hfl sync' to sync the remaining changes")
+		return
 	}
 
-	if cfg.Notion.DatabaseID == "" {
-		fmt.Fprintf(os.Stderr, "Error: Notion database ID not configured\n")
-		fmt.Fprintf(os.Stderr, "Set it with: hfl config set notion.database_id \"your-db-id\"\n")
-		os.Exit(1)
+	if run := syncState.Run; run != nil && resume {
+		fmt.Printf("Resuming %s started %s\n", run.Mode, run.StartedAt)
+		pushOnly = run.Mode == "push"
+		pullOnly = run.Mode == "pull"
+	} else if run != nil {
+		fmt.Printf("Warning: the %s started %s was interrupted; run 'hfl sync --resume' to finish it as it was started\n", run.Mode, run.StartedAt)
+	} else if resume {
+		fmt.Println("No interrupted sync to resume")
+		return
 	}
 
-	// Ensure .hfl/ is gitignored (before creating any .hfl files)
-	if err := gitignore.EnsureHFLIgnored(); err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: could not update .gitignore: %v\n", err)
+	// Link entries to documents that already exist before anything is
+	// created or pulled, so a fresh clone doesn't duplicate or overwrite them
+	if err := engine.AdoptExisting(journal, syncState); err != nil {
+		failSync(engine, "Failed to match existing documents", err)
 	}
 
-	journal, warnings, err := parser.ParseFile("hfl.md")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading hfl.md: %v\n", err)
+	// Recorded until the sync completes, so an interrupted one can be resumed
+	mode := "sync"
+	if pushOnly {
+		mode = "push"
+	} else if pullOnly {
+		mode = "pull"
+	}
+	syncState.BeginRun(mode)
+	if err := syncState.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving state: %v\n", err)
 		os.Exit(1)
 	}
 
-	if len(warnings) > 0 {
-		fmt.Println("Warnings in hfl.md:")
-		for _, warning := range warnings {
-			fmt.Println("  " + warning)
+	if pullOnly {
+		fmt.Printf("Pulling changes from %s...\n", remote)
+		if err := performPullSync(engine, journal, syncState); err != nil {
+			failSync(engine, "Pull sync failed", err)
+		}
+	} else if pushOnly {
+		fmt.Printf("Pushing changes to %s...\n", remote)
+		if err := performPushSync(engine, journal, syncState); err != nil {
+			failSync(engine, "Push sync failed", err)
+		}
+	} else {
+		fmt.Println("Starting two-way sync...")
+		if err := performTwoWaySync(engine, journal, syncState, cfg); err != nil {
+			failSync(engine, "Sync failed", err)
 		}
-		fmt.Println()
 	}
 
-	syncState, err := state.Load()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading sync state: %v\n", err)
+	if !pullOnly && len(tombstones) > 0 {
+		if prune {
+			if err := pruneDeleted(engine, syncState, tombstones); err != nil {
+				failSync(engine, "Prune failed", err)
+			}
+		} else {
+			fmt.Printf("%d entries were deleted from hfl.md; run 'hfl sync --prune' to delete them in %s\n", len(tombstones), remote)
+		}
+	}
+
+	syncState.EndRun()
+	if err := syncState.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving state: %v\n", err)
 		os.Exit(1)
 	}
 
-	syncService := notion.NewSyncService(cfg.Notion.ApiToken, cfg.Notion.DatabaseID)
+	hflsync.WriteReport(os.Stdout, engine.Report())
+	commitHistory(historyNotes(engine.Report(), remote))
+	fmt.Println("Sync completed successfully!")
+}
+
+// failSync reports a failed sync and exits. Unless only pulling, local
+// changes not pushed yet are queued first, so the next sync pushes them.
+// They are queued against hfl.md and state as saved, since a failed pull
+// may have left the ones in memory out of step.
+func failSync(engine *hflsync.Engine, what string, err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", what, err)
+	if pullOnly {
+		os.Exit(1)
+	}
 
-	if err := syncService.ValidateAndSetupDatabase(); err != nil {
-		fmt.Fprintf(os.Stderr, "Database schema validation failed: %v\n", err)
+	journal, _, parseErr := parser.ParseFile("hfl.md")
+	syncState, loadErr := state.Load(engine.Backend().Name())
+	if parseErr != nil || loadErr != nil {
+		os.Exit(1)
 	}
 
-	if dryRun {
-		fmt.Println("Dry run mode - no changes will be made")
-		showSyncPlan(journal, syncState)
-		return
+	queued := engine.QueuePending(journal, syncState, err)
+	if queued == 0 {
+		os.Exit(1)
 	}
 
-	if pullOnly {
-		fmt.Println("Pulling changes from Notion...")
-		if err := performPullSync(syncService, journal, syncState); err != nil {
-			fmt.Fprintf(os.Stderr, "Pull sync failed: %v\n", err)
-			os.Exit(1)
+	if hflsync.Unreachable(err) {
+		fmt.Fprintf(os.Stderr, "%s is unreachable; %d changes queued, run 'hfl sync' again once online\n", backendTitle(engine.Backend().Name()), queued)
+	} else {
+		fmt.Fprintf(os.Stderr, "%d changes queued for the next sync (see 'hfl status')\n", queued)
+	}
+	os.Exit(1)
+}
+
+// historyNotes describes the entries a sync changed in hfl.md, for the
+// history commit
+func historyNotes(report []hflsync.ReportItem, remote string) map[string]string {
+	notes := make(map[string]string)
+	for _, item := range report {
+		switch item.Action {
+		case hflsync.ActionPulled:
+			notes[item.Date] = fmt.Sprintf("pull %s from %s", item.Date, remote)
+		case hflsync.ActionDeleted:
+			notes[item.Date] = fmt.Sprintf("delete %s (deleted in %s)", item.Date, remote)
 		}
-	} else if pushOnly {
-		fmt.Println("Pushing changes to Notion...")
-		if err := performPushSync(syncService, journal, syncState); err != nil {
-			fmt.Fprintf(os.Stderr, "Push sync failed: %v\n", err)
-			os.Exit(1)
+	}
+	return notes
+}
+
+// pruneDeleted deletes the remote documents of entries deleted from
+// hfl.md after asking for confirmation
+func pruneDeleted(engine *hflsync.Engine, syncState *state.State, dates []string) error {
+	fmt.Printf("Entries deleted from hfl.md (%d):\n", len(dates))
+	for _, date := range dates {
+		fmt.Printf("  %s\n", date)
+	}
+
+	if !confirm(fmt.Sprintf("Delete these %d documents in %s?", len(dates), backendTitle(engine.Backend().Name()))) {
+		fmt.Println("Prune cancelled")
+		return nil
+	}
+
+	for _, date := range dates {
+		if err := engine.Archive(syncState, date); err != nil {
+			return err
 		}
-	} else {
-		fmt.Println("Starting two-way sync...")
-		if err := performTwoWaySync(syncService, journal, syncState, cfg); err != nil {
-			fmt.Fprintf(os.Stderr, "Sync failed: %v\n", err)
-			os.Exit(1)
+		fmt.Printf("Deleted %s\n", date)
+	}
+
+	return nil
+}
+
+// checkSchema reports mapped properties the database is missing or has
+// with another type. Missing properties are only added when canAdd and
+// the user agrees; the schema is never changed otherwise.
+func checkSchema(syncService *notion.SyncService, canAdd bool) error {
+	problems, err := syncService.ValidateDatabase()
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	fmt.Println("The Notion database doesn't match notion.properties:")
+	addable := 0
+	for _, problem := range problems {
+		fmt.Printf("  %s\n", problem)
+		if problem.CanAdd() {
+			addable++
 		}
 	}
 
-	fmt.Println("Sync completed successfully!")
+	if canAdd && addable == len(problems) && confirm(fmt.Sprintf("Add the %d missing properties to the database?", addable)) {
+		return syncService.AddMissingProperties(problems)
+	}
+	return fmt.Errorf("map each field to an existing property with 'hfl config set notion.properties.<field> <name>', or disable it with %q", config.PropertyDisabled)
+}
+
+// confirm asks a yes/no question on stdin; anything but "y" means no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	var answer string
+	fmt.Scanln(&answer)
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
-func performPushSync(syncService *notion.SyncService, journal *parser.Journal, syncState *state.State) error {
-	return syncService.SyncToNotion(journal, syncState)
+func performPushSync(engine *hflsync.Engine, journal *parser.Journal, syncState *state.State) error {
+	return engine.Push(journal, syncState)
 }
 
-func performPullSync(syncService *notion.SyncService, journal *parser.Journal, syncState *state.State) error {
-	err := syncService.SyncFromNotion(journal, syncState)
+func performPullSync(engine *hflsync.Engine, journal *parser.Journal, syncState *state.State) error {
+	err := engine.Pull(journal, syncState)
 	if err != nil {
 		return err
 	}
@@ -120,20 +275,21 @@ func performPullSync(syncService *notion.SyncService, journal *parser.Journal, s
 		return fmt.Errorf("failed to write updated journal: %w", err)
 	}
 
-	return nil
+	// Saved only now, so state never records a body hfl.md doesn't have
+	return syncState.Save()
 }
 
-func performTwoWaySync(syncService *notion.SyncService, journal *parser.Journal, syncState *state.State, cfg *config.Config) error {
+func performTwoWaySync(engine *hflsync.Engine, journal *parser.Journal, syncState *state.State, cfg *config.Config) error {
 	strategy := cfg.ConflictStrategy
 	if strategy == "" {
 		strategy = "remote"
 	}
 
-	if strategy == "merge" {
-		return fmt.Errorf("merge conflict strategy not implemented in this version")
+	if strategy != "local" && strategy != "remote" && strategy != "merge" {
+		return fmt.Errorf("unknown conflict strategy: %s", strategy)
 	}
 
-	conflicts, err := detectConflicts(syncService, journal, syncState)
+	conflicts, err := detectConflicts(engine, journal, syncState)
 	if err != nil {
 		return fmt.Errorf("failed to detect conflicts: %w", err)
 	}
@@ -141,40 +297,63 @@ func performTwoWaySync(syncService *notion.SyncService, journal *parser.Journal,
 	if len(conflicts) > 0 {
 		fmt.Printf("Found %d conflicts:\n", len(conflicts))
 		for _, conflict := range conflicts {
-			fmt.Printf("  - %s: both local and remote modified\n", conflict)
+			fmt.Printf("  - %s: both local and remote modified (remote edited %s)\n",
+				conflict.Date, conflict.RemoteEditedAt.Local().Format("2006-01-02 15:04"))
+		}
+	}
+
+	if interactive {
+		if err := resolveConflicts(engine, journal, syncState, cfg); err != nil {
+			return fmt.Errorf("failed to resolve conflicts: %w", err)
+		}
+	} else if len(conflicts) > 0 && strategy != "merge" {
+		fmt.Printf("Resolving conflicts: %s wins\n", strategy)
+		for _, conflict := range conflicts {
+			syncState.Resolve(conflict.Date, strategy)
+		}
+	} else if len(conflicts) > 0 {
+		fmt.Println("Resolving conflicts: three-way merge")
+
+		unresolved, err := engine.MergeConflicts(journal, syncState, conflicts)
+		if err != nil {
+			return fmt.Errorf("failed to merge conflicts: %w", err)
 		}
 
-		switch strategy {
-		case "local":
-			fmt.Println("Resolving conflicts: local wins")
-			return performPushSync(syncService, journal, syncState)
-		case "remote":
-			fmt.Println("Resolving conflicts: remote wins")
-			return performPullSync(syncService, journal, syncState)
-		default:
-			return fmt.Errorf("unknown conflict strategy: %s", strategy)
+		for _, date := range unresolved {
+			fmt.Printf("Warning: %s has overlapping changes; conflict markers written to hfl.md\n", date)
+		}
+		if len(unresolved) > 0 {
+			fmt.Println("Resolve the markers, then run 'hfl check' and 'hfl sync' again.")
 		}
 	}
 
-	fmt.Println("Pushing local changes...")
-	if err := syncService.SyncToNotion(journal, syncState); err != nil {
-		return err
+	// Conflicted entries only sync in the direction of their resolution;
+	// unresolved ones are skipped both ways. hfl.md is written right after
+	// the pull so state never records a body the file doesn't have.
+	if strategy == "local" {
+		fmt.Println("Pushing local changes...")
+		if err := performPushSync(engine, journal, syncState); err != nil {
+			return err
+		}
+
+		fmt.Println("Pulling remote changes...")
+		return performPullSync(engine, journal, syncState)
 	}
 
 	fmt.Println("Pulling remote changes...")
-	if err := syncService.SyncFromNotion(journal, syncState); err != nil {
+	if err := performPullSync(engine, journal, syncState); err != nil {
 		return err
 	}
 
-	return writer.WriteFile("hfl.md", journal)
+	fmt.Println("Pushing local changes...")
+	return performPushSync(engine, journal, syncState)
 }
 
-func detectConflicts(syncService *notion.SyncService, journal *parser.Journal, syncState *state.State) ([]string, error) {
-	// For now, return empty conflicts - implement proper conflict detection later
-	return []string{}, nil
+func detectConflicts(engine *hflsync.Engine, journal *parser.Journal, syncState *state.State) ([]hflsync.Conflict, error) {
+	return engine.DetectConflicts(journal, syncState)
 }
 
-func showSyncPlan(journal *parser.Journal, syncState *state.State) {
+func showSyncPlan(journal *parser.Journal, syncState *state.State, remote string) {
 	fmt.Printf("Sync Plan for %d local entries:\n\n", len(journal.Entries))
 
 	newCount := 0
@@ -185,11 +364,13 @@ func showSyncPlan(journal *parser.Journal, syncState *state.State) {
 		entryState, exists := syncState.GetEntry(entry.Date)
 		wordCount := len(strings.Fields(entry.Body))
 
-		if !exists || entryState.NotionID == "" {
-			fmt.Printf("%s: NEW (%d words, will create in Notion)\n", entry.Date, wordCount)
+		if entryState.Unlinked {
+			fmt.Printf("%s: UNLINKED (%d words, deleted in %s, kept locally)\n", entry.Date, wordCount, remote)
+		} else if !exists || entryState.RemoteID == "" {
+			fmt.Printf("%s: NEW (%d words, will link or create in %s)\n", entry.Date, wordCount, remote)
 			newCount++
 		} else if syncState.HasChanged(entry.Date, entry.Body) {
-			fmt.Printf("%s: MODIFIED (%d words, will update in Notion)\n", entry.Date, wordCount)
+			fmt.Printf("%s: MODIFIED (%d words, will update in %s)\n", entry.Date, wordCount, remote)
 			modifiedCount++
 		} else {
 			fmt.Printf("%s: SYNCED (%d words, no changes)\n", entry.Date, wordCount)
@@ -197,13 +378,23 @@ func showSyncPlan(journal *parser.Journal, syncState *state.State) {
 		}
 	}
 
-	fmt.Printf("\nSummary: %d new, %d modified, %d synced\n", newCount, modifiedCount, syncedCount)
+	deletedCount := 0
+	for _, date := range syncState.Tombstones() {
+		fmt.Printf("%s: DELETED (will delete in %s with --prune)\n", date, remote)
+		deletedCount++
+	}
+
+	fmt.Printf("\nSummary: %d new, %d modified, %d synced, %d deleted\n", newCount, modifiedCount, syncedCount, deletedCount)
 }
 
 func init() {
-	syncCmd.Flags().BoolVar(&pushOnly, "push", false, "Only push local changes to Notion")
-	syncCmd.Flags().BoolVar(&pullOnly, "pull", false, "Only pull changes from Notion")
+	syncCmd.Flags().BoolVar(&pushOnly, "push", false, "Only push local changes to the backend")
+	syncCmd.Flags().BoolVar(&pullOnly, "pull", false, "Only pull changes from the backend")
 	syncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be synced without making changes")
+	syncCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Resolve each conflict interactively")
+	syncCmd.Flags().BoolVar(&prune, "prune", false, "Delete remote documents of entries deleted from hfl.md")
+	syncCmd.Flags().BoolVar(&resume, "resume", false, "Finish an interrupted sync in the mode it was started")
+	syncCmd.Flags().BoolVar(&relink, "relink", false, "Rebuild sync state by matching entries to existing remote documents")
 
 	RootCmd.AddCommand(syncCmd)
 }
@@ -6,17 +6,90 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 )
 
 type NotionConfig struct {
-	ApiToken   string `json:"api_token,omitempty"`
-	DatabaseID string `json:"database_id,omitempty"`
+	ApiToken   string  `json:"api_token,omitempty"`
+	DatabaseID string  `json:"database_id,omitempty"`
+	RateLimit  float64 `json:"rate_limit,omitempty"`  // requests per second
+	MaxRetries *int    `json:"max_retries,omitempty"` // retries for transient failures; 0 disables them
+
+	// OnRemoteDelete decides what happens to a local entry whose page was
+	// archived or trashed in Notion: "unlink" (default), "delete" or "recreate"
+	OnRemoteDelete string `json:"on_remote_delete,omitempty"`
+
+	// TitleTemplate fills the page title, e.g. "{date} {sentence}"
+	TitleTemplate string `json:"title_template,omitempty"`
+	TitleLength   int    `json:"title_length,omitempty"` // max characters of {sentence}
+
+	// Properties maps the fields hfl writes to the database's own
+	// property names
+	Properties PropertyMap `json:"properties,omitempty"`
+}
+
+// PropertyMap maps a field to the name of the database property it's
+// written to. Unmapped fields use their default name; "none" disables them.
+type PropertyMap map[string]string
+
+// PropertyFields are the fields hfl writes to each page, in order
+var PropertyFields = []string{"title", "date", "hfl_date", "word_count", "sync_status"}
+
+// PropertyDisabled turns a field off
+const PropertyDisabled = "none"
+
+// defaultProperties are the property names of unmapped fields. "title" is
+// the ID Notion gives every database's title property, whatever its name.
+var defaultProperties = map[string]string{
+	"title":       "title",
+	"date":        "Date",
+	"hfl_date":    "HFL_Date",
+	"word_count":  "Word Count",
+	"sync_status": "Sync Status",
+}
+
+// TitlePlaceholders are the fields a title template can use
+var TitlePlaceholders = []string{"{date}", "{sentence}"}
+
+// SyncConfig picks what hfl.md is synced with
+type SyncConfig struct {
+	Backend string `json:"backend,omitempty"`
 }
 
+// Backends are the sync targets hfl can use
+var Backends = []string{"notion", "obsidian"}
+
+// DefaultBackend is used when sync.backend isn't set
+const DefaultBackend = "notion"
+
+// ObsidianConfig places entries in a folder of daily notes, one file per
+// date
+type ObsidianConfig struct {
+	Vault   string `json:"vault,omitempty"`   // folder the pattern is relative to
+	Pattern string `json:"pattern,omitempty"` // path of each note, e.g. "Daily/{date}.md"
+
+	// Frontmatter adds a YAML block with the date to new notes
+	Frontmatter *bool `json:"frontmatter,omitempty"`
+}
+
+// HistoryConfig keeps a history of hfl.md
+type HistoryConfig struct {
+	// Git commits hfl.md to the journal's git repository after every
+	// command that changes it
+	Git *bool `json:"git,omitempty"`
+}
+
+// PatternPlaceholders are the fields a note pattern can use
+var PatternPlaceholders = []string{"{date}", "{year}", "{month}", "{day}"}
+
 type Config struct {
-	Editor           string       `json:"editor,omitempty"`
-	ConflictStrategy string       `json:"conflict_strategy,omitempty"`
-	Notion           NotionConfig `json:"notion,omitempty"`
+	Editor           string         `json:"editor,omitempty"`
+	ConflictStrategy string         `json:"conflict_strategy,omitempty"`
+	Sync             SyncConfig     `json:"sync,omitempty"`
+	Notion           NotionConfig   `json:"notion,omitempty"`
+	Obsidian         ObsidianConfig `json:"obsidian,omitempty"`
+	History          HistoryConfig  `json:"history,omitempty"`
 }
 
 func Load() (*Config, error) {
@@ -109,11 +182,66 @@ func (c *Config) Set(key, value string) error {
 			return fmt.Errorf("invalid conflict strategy: %s (must be remote, local, or merge)", value)
 		}
 		c.ConflictStrategy = value
+	case "sync.backend":
+		if !isBackend(value) {
+			return fmt.Errorf("invalid sync backend: %s (must be one of %s)", value, strings.Join(Backends, ", "))
+		}
+		c.Sync.Backend = value
+	case "history.git":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid history.git setting: %s (must be true or false)", value)
+		}
+		c.History.Git = &enabled
+	case "obsidian.vault":
+		c.Obsidian.Vault = value
+	case "obsidian.pattern":
+		if err := validatePattern(value); err != nil {
+			return err
+		}
+		c.Obsidian.Pattern = value
+	case "obsidian.frontmatter":
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid frontmatter setting: %s (must be true or false)", value)
+		}
+		c.Obsidian.Frontmatter = &enabled
 	case "notion.api_token":
 		c.Notion.ApiToken = value
 	case "notion.database_id":
 		c.Notion.DatabaseID = value
+	case "notion.rate_limit":
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			return fmt.Errorf("invalid rate limit: %s (must be a positive number of requests per second)", value)
+		}
+		c.Notion.RateLimit = rate
+	case "notion.max_retries":
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return fmt.Errorf("invalid max retries: %s (must be 0 or a positive integer)", value)
+		}
+		c.Notion.MaxRetries = &retries
+	case "notion.on_remote_delete":
+		if value != "unlink" && value != "delete" && value != "recreate" {
+			return fmt.Errorf("invalid remote delete policy: %s (must be unlink, delete, or recreate)", value)
+		}
+		c.Notion.OnRemoteDelete = value
+	case "notion.title_template":
+		if err := validateTitleTemplate(value); err != nil {
+			return err
+		}
+		c.Notion.TitleTemplate = value
+	case "notion.title_length":
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 1 {
+			return fmt.Errorf("invalid title length: %s (must be a positive integer)", value)
+		}
+		c.Notion.TitleLength = length
 	default:
+		if field, ok := propertyKey(key); ok {
+			return c.Notion.setProperty(field, value)
+		}
 		return fmt.Errorf("unknown config key: %s", key)
 	}
 	return nil
@@ -129,11 +257,43 @@ func (c *Config) Get(key string) (string, error) {
 			strategy = "remote" // Default
 		}
 		return strategy, nil
+	case "sync.backend":
+		return c.Sync.GetBackend(), nil
+	case "history.git":
+		return strconv.FormatBool(c.History.GetGit()), nil
+	case "obsidian.vault":
+		return c.Obsidian.Vault, nil
+	case "obsidian.pattern":
+		return c.Obsidian.GetPattern(), nil
+	case "obsidian.frontmatter":
+		return strconv.FormatBool(c.Obsidian.GetFrontmatter()), nil
 	case "notion.api_token":
 		return c.Notion.ApiToken, nil
 	case "notion.database_id":
 		return c.Notion.DatabaseID, nil
+	case "notion.rate_limit":
+		if c.Notion.RateLimit == 0 {
+			return "", nil
+		}
+		return strconv.FormatFloat(c.Notion.RateLimit, 'f', -1, 64), nil
+	case "notion.max_retries":
+		if c.Notion.MaxRetries == nil {
+			return "", nil
+		}
+		return strconv.Itoa(*c.Notion.MaxRetries), nil
+	case "notion.on_remote_delete":
+		return c.Notion.GetOnRemoteDelete(), nil
+	case "notion.title_template":
+		return c.Notion.GetTitleTemplate(), nil
+	case "notion.title_length":
+		return strconv.Itoa(c.Notion.GetTitleLength()), nil
 	default:
+		if field, ok := propertyKey(key); ok {
+			if name := c.Notion.Properties.Name(field); name != "" {
+				return name, nil
+			}
+			return PropertyDisabled, nil
+		}
 		return "", fmt.Errorf("unknown config key: %s", key)
 	}
 }
@@ -157,6 +317,163 @@ func (c *Config) GetEditor() string {
 	return "vi"
 }
 
+// GetBackend returns the sync backend, defaulting to Notion
+func (s SyncConfig) GetBackend() string {
+	if s.Backend != "" {
+		return s.Backend
+	}
+	return DefaultBackend
+}
+
+func isBackend(name string) bool {
+	for _, backend := range Backends {
+		if name == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// GetGit reports whether hfl.md is committed after each change, defaulting
+// to no
+func (h HistoryConfig) GetGit() bool {
+	return h.Git != nil && *h.Git
+}
+
+// GetPattern returns the path of each note, defaulting to one file per
+// date at the top of the vault
+func (o ObsidianConfig) GetPattern() string {
+	if o.Pattern != "" {
+		return o.Pattern
+	}
+	return "{date}.md"
+}
+
+// GetFrontmatter reports whether new notes get frontmatter, defaulting to no
+func (o ObsidianConfig) GetFrontmatter() bool {
+	return o.Frontmatter != nil && *o.Frontmatter
+}
+
+// GetOnRemoteDelete returns the remote delete policy, defaulting to "unlink"
+func (n NotionConfig) GetOnRemoteDelete() string {
+	if n.OnRemoteDelete != "" {
+		return n.OnRemoteDelete
+	}
+	return "unlink"
+}
+
+// GetTitleTemplate returns the page title template, defaulting to the
+// first sentence of the entry
+func (n NotionConfig) GetTitleTemplate() string {
+	if n.TitleTemplate != "" {
+		return n.TitleTemplate
+	}
+	return "{sentence}"
+}
+
+// GetTitleLength returns the longest {sentence} in a title, defaulting to 60
+func (n NotionConfig) GetTitleLength() int {
+	if n.TitleLength > 0 {
+		return n.TitleLength
+	}
+	return 60
+}
+
+// Name returns the property a field is written to, or "" when it's disabled
+func (p PropertyMap) Name(field string) string {
+	name, ok := p[field]
+	if !ok || name == "" {
+		return defaultProperties[field]
+	}
+	if name == PropertyDisabled {
+		return ""
+	}
+	return name
+}
+
+// propertyKey returns the field of a notion.properties.<field> key
+func propertyKey(key string) (string, bool) {
+	field, ok := strings.CutPrefix(key, "notion.properties.")
+	if !ok {
+		return "", false
+	}
+	for _, known := range PropertyFields {
+		if field == known {
+			return field, true
+		}
+	}
+	return "", false
+}
+
+// setProperty maps field to a property name. An empty name restores the
+// default. Pages can't be matched to entries without a date, and two
+// fields can't share a property.
+func (n *NotionConfig) setProperty(field, name string) error {
+	name = strings.TrimSpace(name)
+
+	properties := make(PropertyMap, len(n.Properties)+1)
+	for key, value := range n.Properties {
+		properties[key] = value
+	}
+	if name == "" {
+		delete(properties, field)
+	} else {
+		properties[field] = name
+	}
+
+	if properties.Name("date") == "" && properties.Name("hfl_date") == "" {
+		return fmt.Errorf("invalid property mapping: date and hfl_date can't both be %s", PropertyDisabled)
+	}
+	for _, other := range PropertyFields {
+		if other != field && name != PropertyDisabled && properties.Name(other) == properties.Name(field) {
+			return fmt.Errorf("invalid property mapping: %s is already used by %s", properties.Name(field), other)
+		}
+	}
+
+	n.Properties = properties
+	return nil
+}
+
+// validateTitleTemplate rejects templates with unknown placeholders
+func validateTitleTemplate(template string) error {
+	rest := template
+	for _, placeholder := range TitlePlaceholders {
+		rest = strings.ReplaceAll(rest, placeholder, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("invalid title template: %s (placeholders are %s)", template, strings.Join(TitlePlaceholders, ", "))
+	}
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("invalid title template: must not be empty")
+	}
+	return nil
+}
+
+// validatePattern rejects note patterns with unknown placeholders, or
+// that don't name one Markdown file per date
+func validatePattern(pattern string) error {
+	rest := pattern
+	for _, placeholder := range PatternPlaceholders {
+		rest = strings.ReplaceAll(rest, placeholder, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("invalid note pattern: %s (placeholders are %s)", pattern, strings.Join(PatternPlaceholders, ", "))
+	}
+
+	hasDate := strings.Contains(pattern, "{date}")
+	hasParts := strings.Contains(pattern, "{year}") && strings.Contains(pattern, "{month}") && strings.Contains(pattern, "{day}")
+	if !hasDate && !hasParts {
+		return fmt.Errorf("invalid note pattern: %s (must contain {date}, or {year}, {month} and {day})", pattern)
+	}
+	if !strings.HasSuffix(pattern, ".md") {
+		return fmt.Errorf("invalid note pattern: %s (must end in .md)", pattern)
+	}
+	if filepath.IsAbs(pattern) || strings.HasPrefix(filepath.Clean(pattern), "..") {
+		return fmt.Errorf("invalid note pattern: %s (must be a path inside the vault)", pattern)
+	}
+	return nil
+}
+
 // mergeConfig merges source config into target config (source overrides target)
 func mergeConfig(target, source *Config) {
 	if source.Editor != "" {
@@ -165,12 +482,48 @@ func mergeConfig(target, source *Config) {
 	if source.ConflictStrategy != "" {
 		target.ConflictStrategy = source.ConflictStrategy
 	}
+	if source.Sync.Backend != "" {
+		target.Sync.Backend = source.Sync.Backend
+	}
+	if source.History.Git != nil {
+		target.History.Git = source.History.Git
+	}
+	if source.Obsidian.Vault != "" {
+		target.Obsidian.Vault = source.Obsidian.Vault
+	}
+	if source.Obsidian.Pattern != "" {
+		target.Obsidian.Pattern = source.Obsidian.Pattern
+	}
+	if source.Obsidian.Frontmatter != nil {
+		target.Obsidian.Frontmatter = source.Obsidian.Frontmatter
+	}
 	if source.Notion.ApiToken != "" {
 		target.Notion.ApiToken = source.Notion.ApiToken
 	}
 	if source.Notion.DatabaseID != "" {
 		target.Notion.DatabaseID = source.Notion.DatabaseID
 	}
+	if source.Notion.RateLimit != 0 {
+		target.Notion.RateLimit = source.Notion.RateLimit
+	}
+	if source.Notion.MaxRetries != nil {
+		target.Notion.MaxRetries = source.Notion.MaxRetries
+	}
+	if source.Notion.OnRemoteDelete != "" {
+		target.Notion.OnRemoteDelete = source.Notion.OnRemoteDelete
+	}
+	if source.Notion.TitleTemplate != "" {
+		target.Notion.TitleTemplate = source.Notion.TitleTemplate
+	}
+	if source.Notion.TitleLength != 0 {
+		target.Notion.TitleLength = source.Notion.TitleLength
+	}
+	for field, name := range source.Notion.Properties {
+		if target.Notion.Properties == nil {
+			target.Notion.Properties = make(PropertyMap)
+		}
+		target.Notion.Properties[field] = name
+	}
 }
 
 // applyEnvOverrides applies environment variable overrides to config
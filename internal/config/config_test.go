@@ -389,3 +389,221 @@ func TestLoad_InvalidJSON(t *testing.T) {
 		t.Error("Expected error when loading invalid JSON config")
 	}
 }
+
+func TestSet_NotionRetryLimits(t *testing.T) {
+	config := &Config{}
+
+	if err := config.Set("notion.rate_limit", "2.5"); err != nil {
+		t.Fatalf("Set rate_limit failed: %v", err)
+	}
+
+	if err := config.Set("notion.max_retries", "8"); err != nil {
+		t.Fatalf("Set max_retries failed: %v", err)
+	}
+
+	if config.Notion.RateLimit != 2.5 {
+		t.Errorf("Expected rate limit 2.5, got %v", config.Notion.RateLimit)
+	}
+
+	if value, _ := config.Get("notion.max_retries"); value != "8" {
+		t.Errorf("Expected max_retries '8', got %q", value)
+	}
+
+	// Invalid values are rejected
+	if err := config.Set("notion.rate_limit", "0"); err == nil {
+		t.Error("Expected error for zero rate limit")
+	}
+
+	if err := config.Set("notion.max_retries", "lots"); err == nil {
+		t.Error("Expected error for non-numeric max_retries")
+	}
+
+	if err := config.Set("notion.max_retries", "-1"); err == nil {
+		t.Error("Expected error for negative max_retries")
+	}
+
+	// Zero turns retries off, and overrides a global value
+	local := &Config{}
+	if err := local.Set("notion.max_retries", "0"); err != nil {
+		t.Fatalf("Set max_retries 0 failed: %v", err)
+	}
+	mergeConfig(config, local)
+	if value, _ := config.Get("notion.max_retries"); value != "0" {
+		t.Errorf("Expected max_retries '0', got %q", value)
+	}
+}
+
+func TestSet_OnRemoteDelete(t *testing.T) {
+	config := &Config{}
+
+	if value, _ := config.Get("notion.on_remote_delete"); value != "unlink" {
+		t.Errorf("Expected default policy 'unlink', got %q", value)
+	}
+
+	for _, policy := range []string{"unlink", "delete", "recreate"} {
+		if err := config.Set("notion.on_remote_delete", policy); err != nil {
+			t.Errorf("Expected policy %q to be accepted: %v", policy, err)
+		}
+	}
+
+	if err := config.Set("notion.on_remote_delete", "ignore"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
+
+func TestSet_TitleTemplate(t *testing.T) {
+	config := &Config{}
+
+	if value, _ := config.Get("notion.title_template"); value != "{sentence}" {
+		t.Errorf("Expected default template '{sentence}', got %q", value)
+	}
+	if value, _ := config.Get("notion.title_length"); value != "60" {
+		t.Errorf("Expected default length 60, got %q", value)
+	}
+
+	for _, template := range []string{"{date}", "{date} – {sentence}", "Journal {date}"} {
+		if err := config.Set("notion.title_template", template); err != nil {
+			t.Errorf("Expected template %q to be accepted: %v", template, err)
+		}
+	}
+
+	for _, template := range []string{"{title}", "{date", "  "} {
+		if err := config.Set("notion.title_template", template); err == nil {
+			t.Errorf("Expected template %q to be rejected", template)
+		}
+	}
+
+	if err := config.Set("notion.title_length", "0"); err == nil {
+		t.Error("Expected error for zero title length")
+	}
+}
+
+func TestSet_Properties(t *testing.T) {
+	config := &Config{}
+
+	if value, _ := config.Get("notion.properties.word_count"); value != "Word Count" {
+		t.Errorf("Expected default name 'Word Count', got %q", value)
+	}
+
+	if err := config.Set("notion.properties.date", "Tanggal"); err != nil {
+		t.Fatalf("Set date property failed: %v", err)
+	}
+	if err := config.Set("notion.properties.hfl_date", "none"); err != nil {
+		t.Fatalf("Disabling hfl_date failed: %v", err)
+	}
+
+	if name := config.Notion.Properties.Name("date"); name != "Tanggal" {
+		t.Errorf("Expected date to be written to 'Tanggal', got %q", name)
+	}
+	if name := config.Notion.Properties.Name("hfl_date"); name != "" {
+		t.Errorf("Expected hfl_date to be disabled, got %q", name)
+	}
+	if value, _ := config.Get("notion.properties.hfl_date"); value != "none" {
+		t.Errorf("Expected 'none' for a disabled field, got %q", value)
+	}
+
+	// Without either date pages can't be matched to entries
+	if err := config.Set("notion.properties.date", "none"); err == nil {
+		t.Error("Expected error for disabling both dates")
+	}
+	if err := config.Set("notion.properties.sync_status", "Tanggal"); err == nil {
+		t.Error("Expected error for two fields sharing a property")
+	}
+	if err := config.Set("notion.properties.mood", "Mood"); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+
+	// An empty name restores the default
+	if err := config.Set("notion.properties.date", ""); err != nil {
+		t.Fatalf("Resetting date property failed: %v", err)
+	}
+	if name := config.Notion.Properties.Name("date"); name != "Date" {
+		t.Errorf("Expected default name 'Date', got %q", name)
+	}
+}
+
+func TestMergeConfig_Properties(t *testing.T) {
+	target := &Config{}
+	mergeConfig(target, &Config{Notion: NotionConfig{Properties: PropertyMap{"date": "Day", "word_count": "none"}}})
+	mergeConfig(target, &Config{Notion: NotionConfig{Properties: PropertyMap{"date": "Tanggal"}}})
+
+	if name := target.Notion.Properties.Name("date"); name != "Tanggal" {
+		t.Errorf("Expected local mapping to win, got %q", name)
+	}
+	if name := target.Notion.Properties.Name("word_count"); name != "" {
+		t.Errorf("Expected global mapping to be kept, got %q", name)
+	}
+}
+
+func TestSet_SyncBackend(t *testing.T) {
+	config := &Config{}
+
+	if value, _ := config.Get("sync.backend"); value != "notion" {
+		t.Errorf("Expected default backend 'notion', got %q", value)
+	}
+
+	if err := config.Set("sync.backend", "notion"); err != nil {
+		t.Errorf("Expected backend 'notion' to be accepted: %v", err)
+	}
+	if err := config.Set("sync.backend", "dropbox"); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
+
+func TestSet_Obsidian(t *testing.T) {
+	config := &Config{}
+
+	if value, _ := config.Get("obsidian.pattern"); value != "{date}.md" {
+		t.Errorf("Expected default pattern '{date}.md', got %q", value)
+	}
+	if value, _ := config.Get("obsidian.frontmatter"); value != "false" {
+		t.Errorf("Expected frontmatter off by default, got %q", value)
+	}
+
+	for _, pattern := range []string{"Daily/{date}.md", "{year}/{month}/{year}-{month}-{day}.md"} {
+		if err := config.Set("obsidian.pattern", pattern); err != nil {
+			t.Errorf("Expected pattern %q to be accepted: %v", pattern, err)
+		}
+	}
+	for _, pattern := range []string{"Daily/{year}.md", "{date}.txt", "{date}-{weekday}.md", "../{date}.md"} {
+		if err := config.Set("obsidian.pattern", pattern); err == nil {
+			t.Errorf("Expected error for pattern %q", pattern)
+		}
+	}
+
+	if err := config.Set("obsidian.frontmatter", "maybe"); err == nil {
+		t.Error("Expected error for frontmatter 'maybe'")
+	}
+
+	// An explicit false overrides a true from the global config
+	global := &Config{}
+	global.Set("obsidian.frontmatter", "true")
+	local := &Config{}
+	local.Set("obsidian.frontmatter", "false")
+
+	merged := &Config{}
+	mergeConfig(merged, global)
+	mergeConfig(merged, local)
+	if merged.Obsidian.GetFrontmatter() {
+		t.Error("Expected local frontmatter false to win")
+	}
+}
+
+func TestSet_HistoryGit(t *testing.T) {
+	config := &Config{}
+
+	if value, _ := config.Get("history.git"); value != "false" {
+		t.Errorf("Expected history.git off by default, got %q", value)
+	}
+
+	if err := config.Set("history.git", "true"); err != nil {
+		t.Fatalf("Expected history.git true to be accepted: %v", err)
+	}
+	if !config.History.GetGit() {
+		t.Error("Expected history.git to be on")
+	}
+
+	if err := config.Set("history.git", "sometimes"); err == nil {
+		t.Error("Expected error for history.git 'sometimes'")
+	}
+}
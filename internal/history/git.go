@@ -0,0 +1,170 @@
+// Package history commits hfl.md to git after the commands that change it,
+// with a message listing which entries changed and how.
+package history
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ahmaruff/hfl/internal/parser"
+)
+
+// ErrNotOurs is returned for a journal inside a repository that belongs to
+// something else, i.e. whose top level is a parent folder
+var ErrNotOurs = errors.New("journal is inside another repository")
+
+// Repo is the git repository of a journal
+type Repo struct {
+	dir string
+}
+
+// Open returns the repository of the journal in dir, creating one when
+// there is none
+func Open(dir string) (*Repo, error) {
+	repo := &Repo{dir: dir}
+
+	top, err := repo.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		if _, err := repo.git("init", "-q"); err != nil {
+			return nil, fmt.Errorf("failed to create repository: %w", err)
+		}
+		return repo, nil
+	}
+
+	same, err := sameDir(strings.TrimSpace(top), dir)
+	if err != nil {
+		return nil, err
+	}
+	if !same {
+		return nil, fmt.Errorf("%w: %s", ErrNotOurs, strings.TrimSpace(top))
+	}
+	return repo, nil
+}
+
+// Commit commits file alone, with a message describing how its entries
+// changed since the last commit. notes describes entries changed by
+// something other than an edit, e.g. "pull 2025-08-14 from Notion". It
+// returns the message, or "" when file didn't change.
+func (r *Repo) Commit(file string, notes map[string]string) (string, error) {
+	current, err := os.ReadFile(filepath.Join(r.dir, file))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	// Missing before the first commit, or before file was first committed
+	committed, err := r.git("show", "HEAD:"+filepath.ToSlash(file))
+	if err == nil && committed == string(current) {
+		return "", nil
+	}
+
+	before, _, err := parser.Parse(strings.NewReader(committed))
+	if err != nil {
+		return "", fmt.Errorf("failed to read committed %s: %w", file, err)
+	}
+	after, _, err := parser.Parse(bytes.NewReader(current))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	message := Message(before, after, notes)
+	if message == "" {
+		message = "format " + file
+	}
+
+	// Only file is staged and committed, whatever else is in the index
+	if _, err := r.git("add", "--", file); err != nil {
+		return "", fmt.Errorf("failed to stage %s: %w", file, err)
+	}
+	if _, err := r.git("commit", "-q", "-m", message, "--", file); err != nil {
+		return "", fmt.Errorf("failed to commit %s: %w", file, err)
+	}
+
+	return message, nil
+}
+
+// Message describes the entries that differ between two versions of a
+// journal, newest first, e.g. "edit 2025-08-16; pull 2025-08-14 from
+// Notion". Entries with a note are described by it.
+func Message(before, after *parser.Journal, notes map[string]string) string {
+	previous := bodies(before)
+	current := bodies(after)
+
+	var dates []string
+	for date := range previous {
+		dates = append(dates, date)
+	}
+	for date := range current {
+		if _, ok := previous[date]; !ok {
+			dates = append(dates, date)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+
+	var changes []string
+	for _, date := range dates {
+		oldBody, existed := previous[date]
+		newBody, exists := current[date]
+		if existed && exists && oldBody == newBody {
+			continue
+		}
+
+		switch {
+		case notes[date] != "":
+			changes = append(changes, notes[date])
+		case !existed:
+			changes = append(changes, "add "+date)
+		case !exists:
+			changes = append(changes, "delete "+date)
+		default:
+			changes = append(changes, "edit "+date)
+		}
+	}
+
+	return strings.Join(changes, "; ")
+}
+
+// bodies maps the dates of a journal to their bodies
+func bodies(journal *parser.Journal) map[string]string {
+	result := make(map[string]string, len(journal.Entries))
+	for _, entry := range journal.Entries {
+		result[entry.Date] = entry.Body
+	}
+	return result
+}
+
+// git runs a git command in the journal's folder and returns its output
+func (r *Repo) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// sameDir reports whether two paths are the same folder
+func sameDir(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
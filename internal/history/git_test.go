@@ -0,0 +1,122 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ahmaruff/hfl/internal/parser"
+)
+
+// newRepo returns the repository of an empty journal folder, skipping the
+// test when git isn't installed
+func newRepo(t *testing.T) (*Repo, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	t.Setenv("GIT_AUTHOR_NAME", "hfl")
+	t.Setenv("GIT_AUTHOR_EMAIL", "hfl@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "hfl")
+	t.Setenv("GIT_COMMITTER_EMAIL", "hfl@example.com")
+
+	dir := t.TempDir()
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	return repo, dir
+}
+
+func writeJournal(t *testing.T, dir, text string) {
+	if err := os.WriteFile(filepath.Join(dir, "hfl.md"), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	before := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-16", Body: "Old."},
+		{Date: "2025-08-15", Body: "Same."},
+		{Date: "2025-08-14", Body: "Old."},
+		{Date: "2025-08-13", Body: "Gone."},
+	}}
+	after := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-17", Body: "New."},
+		{Date: "2025-08-16", Body: "Edited."},
+		{Date: "2025-08-15", Body: "Same."},
+		{Date: "2025-08-14", Body: "Pulled."},
+	}}
+	notes := map[string]string{
+		"2025-08-14": "pull 2025-08-14 from Notion",
+		"2025-08-15": "pull 2025-08-15 from Notion",
+	}
+
+	expected := "add 2025-08-17; edit 2025-08-16; pull 2025-08-14 from Notion; delete 2025-08-13"
+	if message := Message(before, after, notes); message != expected {
+		t.Errorf("Expected %q, got %q", expected, message)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	repo, dir := newRepo(t)
+
+	// State and other staged work stay out of the commits
+	os.MkdirAll(filepath.Join(dir, ".hfl"), 0755)
+	os.WriteFile(filepath.Join(dir, ".hfl", "state.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("draft"), 0644)
+	repo.git("add", "notes.txt")
+
+	writeJournal(t, dir, "# 2025-08-16\nFirst.\n")
+	message, err := repo.Commit("hfl.md", nil)
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if message != "add 2025-08-16" {
+		t.Errorf("Expected 'add 2025-08-16', got %q", message)
+	}
+
+	writeJournal(t, dir, "# 2025-08-16\nSecond.\n\n# 2025-08-14\nFrom Notion.\n")
+	message, err = repo.Commit("hfl.md", map[string]string{"2025-08-14": "pull 2025-08-14 from Notion"})
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if expected := "edit 2025-08-16; pull 2025-08-14 from Notion"; message != expected {
+		t.Errorf("Expected %q, got %q", expected, message)
+	}
+
+	// Nothing changed, nothing to commit
+	if message, err := repo.Commit("hfl.md", nil); err != nil || message != "" {
+		t.Errorf("Expected no commit, got %q, %v", message, err)
+	}
+
+	// Reformatting alone still gets a commit
+	writeJournal(t, dir, "# 2025-08-16\nSecond.\n\n\n# 2025-08-14\nFrom Notion.\n")
+	if message, _ := repo.Commit("hfl.md", nil); message != "format hfl.md" {
+		t.Errorf("Expected 'format hfl.md', got %q", message)
+	}
+
+	files, _ := repo.git("ls-tree", "-r", "--name-only", "HEAD")
+	if strings.TrimSpace(files) != "hfl.md" {
+		t.Errorf("Expected only hfl.md to be committed, got %q", files)
+	}
+
+	log, _ := repo.git("log", "--format=%s")
+	if count := len(strings.Split(strings.TrimSpace(log), "\n")); count != 3 {
+		t.Errorf("Expected 3 commits, got %d:\n%s", count, log)
+	}
+}
+
+func TestOpen_SkipsOtherRepositories(t *testing.T) {
+	_, dir := newRepo(t)
+
+	journal := filepath.Join(dir, "journal")
+	os.Mkdir(journal, 0755)
+
+	if _, err := Open(journal); !errors.Is(err, ErrNotOurs) {
+		t.Errorf("Expected ErrNotOurs, got %v", err)
+	}
+}
@@ -0,0 +1,427 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseInline splits text into styled spans. It understands **bold**,
+// *italic* and _italic_, ***both***, ~~strike~~, <u>underline</u>, `code`,
+// [links](url), nesting of all of these, and backslash escapes.
+func ParseInline(text string) []Span {
+	return parseSpans(text, Span{})
+}
+
+// parseSpans parses text whose spans all carry the style of base
+func parseSpans(text string, base Span) []Span {
+	var spans []Span
+	var plain strings.Builder
+
+	flushPlain := func() {
+		if plain.Len() > 0 {
+			span := base
+			span.Text = plain.String()
+			spans = appendSpan(spans, span)
+			plain.Reset()
+		}
+	}
+
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		case c == '\\' && i+1 < len(text) && isSpecial(text[i+1]):
+			plain.WriteByte(text[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			n := runLength(text, i)
+			if end := findCodeClose(text, i+n, n); end >= 0 {
+				flushPlain()
+				span := base
+				span.Text = text[i+n : end]
+				span.Code = true
+				spans = appendSpan(spans, span)
+				i = end + n
+				continue
+			}
+			plain.WriteString(text[i : i+n])
+			i += n
+			continue
+
+		case c == '[' && base.Link == "":
+			if label, url, end, ok := parseLink(text, i); ok {
+				flushPlain()
+				inner := base
+				inner.Link = url
+				for _, span := range parseSpans(label, inner) {
+					spans = appendSpan(spans, span)
+				}
+				i = end
+				continue
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			n := runLength(text, i)
+			if inner, ok := emphasis(c, n, base); ok && canOpen(text, i, n) {
+				if end := findClose(text, i+n, c, n); end >= 0 {
+					flushPlain()
+					for _, span := range parseSpans(text[i+n:end], inner) {
+						spans = appendSpan(spans, span)
+					}
+					i = end + n
+					continue
+				}
+			}
+			plain.WriteString(text[i : i+n])
+			i += n
+			continue
+
+		case strings.HasPrefix(text[i:], "<u>") && !base.Underline:
+			if end := strings.Index(text[i+3:], "</u>"); end > 0 {
+				flushPlain()
+				inner := base
+				inner.Underline = true
+				for _, span := range parseSpans(text[i+3:i+3+end], inner) {
+					spans = appendSpan(spans, span)
+				}
+				i += 3 + end + 4
+				continue
+			}
+		}
+
+		plain.WriteByte(c)
+		i++
+	}
+
+	flushPlain()
+	return spans
+}
+
+// emphasis returns the style a delimiter run of n c's opens
+func emphasis(c byte, n int, base Span) (Span, bool) {
+	switch {
+	case c == '~' && n == 2 && !base.Strike:
+		base.Strike = true
+	case c != '~' && n == 1 && !base.Italic:
+		base.Italic = true
+	case c != '~' && n == 2 && !base.Bold:
+		base.Bold = true
+	case c != '~' && n == 3 && !base.Bold && !base.Italic:
+		base.Bold, base.Italic = true, true
+	default:
+		return base, false
+	}
+	return base, true
+}
+
+// findClose returns the index of the run that closes a delimiter of n c's
+// opened just before start, or -1. Runs that open a nested span are
+// skipped along with everything up to their own closer, and when a run is
+// longer than needed its first n characters close, leaving the rest to an
+// outer span.
+func findClose(text string, start int, c byte, n int) int {
+	for i := start; i < len(text); {
+		switch {
+		case text[i] == '\\' && i+1 < len(text) && isSpecial(text[i+1]):
+			i += 2
+
+		case text[i] == '`':
+			run := runLength(text, i)
+			if end := findCodeClose(text, i+run, run); end >= 0 {
+				i = end + run
+			} else {
+				i += run
+			}
+
+		case text[i] == c:
+			run := runLength(text, i)
+			if run >= n && i > start && canClose(text, i, run) {
+				return i
+			}
+			if canOpen(text, i, run) {
+				if end := findClose(text, i+run, c, run); end >= 0 {
+					i = end + run
+					continue
+				}
+			}
+			i += run
+
+		default:
+			i++
+		}
+	}
+
+	return -1
+}
+
+// findCodeClose returns the index of the next run of exactly n backticks
+func findCodeClose(text string, start, n int) int {
+	for i := start; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		run := runLength(text, i)
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// canOpen reports whether the delimiter run at text[i:i+n] may open a span:
+// it must be followed by a non-space, and an underscore must not sit
+// inside a word
+func canOpen(text string, i, n int) bool {
+	if i+n >= len(text) || isSpace(text, i+n) {
+		return false
+	}
+	return text[i] != '_' || i == 0 || !isWordChar(text, i, true)
+}
+
+// canClose reports whether the delimiter run at text[i:i+n] may close a
+// span: it must follow a non-space, and an underscore must not sit inside
+// a word
+func canClose(text string, i, n int) bool {
+	if i == 0 || isSpace(text, i-1) {
+		return false
+	}
+	return text[i] != '_' || i+n >= len(text) || !isWordChar(text, i+n, false)
+}
+
+func isSpace(text string, i int) bool {
+	return text[i] == ' ' || text[i] == '\t' || text[i] == '\n'
+}
+
+// isWordChar reports whether the rune just before (before=true) or at
+// index i is a letter or digit
+func isWordChar(text string, i int, before bool) bool {
+	var r rune
+	if before {
+		r, _ = utf8.DecodeLastRuneInString(text[:i])
+	} else {
+		r, _ = utf8.DecodeRuneInString(text[i:])
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// runLength counts the repeats of text[i] starting at i
+func runLength(text string, i int) int {
+	n := 1
+	for i+n < len(text) && text[i+n] == text[i] {
+		n++
+	}
+	return n
+}
+
+// parseLink matches [label](url) at text[start], returning the raw label,
+// the URL and the index just past the closing parenthesis
+func parseLink(text string, start int) (string, string, int, bool) {
+	closeLabel := -1
+	for i := start + 1; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && isSpecial(text[i+1]) {
+			i++
+			continue
+		}
+		if text[i] == '[' {
+			return "", "", 0, false
+		}
+		if text[i] == ']' {
+			closeLabel = i
+			break
+		}
+	}
+
+	if closeLabel <= start+1 || closeLabel+1 >= len(text) || text[closeLabel+1] != '(' {
+		return "", "", 0, false
+	}
+
+	urlStart := closeLabel + 2
+	urlEnd := strings.IndexByte(text[urlStart:], ')')
+	if urlEnd <= 0 {
+		return "", "", 0, false
+	}
+
+	url := text[urlStart : urlStart+urlEnd]
+	if strings.ContainsAny(url, " \t\n") {
+		return "", "", 0, false
+	}
+
+	return text[start+1 : closeLabel], url, urlStart + urlEnd + 1, true
+}
+
+// isSpecial reports whether a backslash escapes c. As in CommonMark, that
+// is any ASCII punctuation character.
+func isSpecial(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// appendSpan appends span, merging it into the last one when both have
+// the same style
+func appendSpan(spans []Span, span Span) []Span {
+	if n := len(spans); n > 0 && sameStyle(spans[n-1], span) {
+		spans[n-1].Text += span.Text
+		return spans
+	}
+	return append(spans, span)
+}
+
+func sameStyle(a, b Span) bool {
+	a.Text, b.Text = "", ""
+	return a == b
+}
+
+// RenderInline writes spans as Markdown that ParseInline reads back as the
+// same spans. Special characters in the text are escaped only when the
+// text would otherwise be read differently.
+func RenderInline(spans []Span) string {
+	escaped := renderSpans(spans, true)
+	canonical := ParseInline(escaped)
+
+	if text := renderSpans(spans, false); equalSpans(ParseInline(text), canonical) {
+		return text
+	}
+	return escaped
+}
+
+// renderSpans writes spans, wrapping each link around the styled spans
+// that share its URL
+func renderSpans(spans []Span, escapeText bool) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(spans); {
+		j := i + 1
+		for j < len(spans) && spans[j].Link == spans[i].Link {
+			j++
+		}
+
+		group := make([]Span, j-i)
+		for k, span := range spans[i:j] {
+			span.Link = ""
+			group[k] = span
+		}
+
+		if url := spans[i].Link; url != "" {
+			// Characters that would end the URL early are percent-encoded
+			url = strings.NewReplacer(" ", "%20", ")", "%29").Replace(url)
+			builder.WriteString("[" + renderStyled(group, escapeText) + "](" + url + ")")
+		} else {
+			builder.WriteString(renderStyled(group, escapeText))
+		}
+
+		i = j
+	}
+
+	return builder.String()
+}
+
+// styles in the order they are opened when runs of equal length tie
+var styles = []struct {
+	open, close string
+	has         func(Span) bool
+	clear       func(*Span)
+}{
+	{"<u>", "</u>", func(s Span) bool { return s.Underline }, func(s *Span) { s.Underline = false }},
+	{"~~", "~~", func(s Span) bool { return s.Strike }, func(s *Span) { s.Strike = false }},
+	{"**", "**", func(s Span) bool { return s.Bold }, func(s *Span) { s.Bold = false }},
+	{"*", "*", func(s Span) bool { return s.Italic }, func(s *Span) { s.Italic = false }},
+}
+
+// renderStyled writes spans without links. The style shared by the
+// longest run of spans is opened first and the run rendered inside it, so
+// nested emphasis is written the way it would be typed.
+func renderStyled(spans []Span, escapeText bool) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(spans); {
+		best, bestEnd := -1, i+1
+		for s, style := range styles {
+			end := i
+			for end < len(spans) && style.has(spans[end]) {
+				end++
+			}
+			if end > i && (best < 0 || end > bestEnd) {
+				best, bestEnd = s, end
+			}
+		}
+
+		if best < 0 {
+			builder.WriteString(renderText(spans[i], escapeText))
+			i++
+			continue
+		}
+
+		inner := make([]Span, bestEnd-i)
+		for k, span := range spans[i:bestEnd] {
+			styles[best].clear(&span)
+			inner[k] = span
+		}
+		text := renderStyled(inner, escapeText)
+
+		// Delimiters can't sit next to whitespace, so move it outside
+		core := strings.TrimSpace(text)
+		if core == "" {
+			builder.WriteString(text)
+		} else {
+			lead := text[:strings.Index(text, core)]
+			trail := text[len(lead)+len(core):]
+			builder.WriteString(lead + styles[best].open + core + styles[best].close + trail)
+		}
+
+		i = bestEnd
+	}
+
+	return builder.String()
+}
+
+func renderText(span Span, escapeText bool) string {
+	if !span.Code {
+		if escapeText {
+			return escapeInline(span.Text)
+		}
+		return span.Text
+	}
+
+	// The fence is one backtick longer than any run inside the code
+	longest := 0
+	for i := 0; i < len(span.Text); i++ {
+		if span.Text[i] == '`' {
+			if n := runLength(span.Text, i); n > longest {
+				longest = n
+			}
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+	return fence + span.Text + fence
+}
+
+// inlineSpecials are the characters escaped in text that would otherwise
+// be read as markup
+const inlineSpecials = "\\[]*_~`<"
+
+func escapeInline(text string) string {
+	var builder strings.Builder
+	for i := 0; i < len(text); i++ {
+		if strings.IndexByte(inlineSpecials, text[i]) >= 0 {
+			builder.WriteByte('\\')
+		}
+		builder.WriteByte(text[i])
+	}
+	return builder.String()
+}
+
+func equalSpans(a, b []Span) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+// Package markdown parses entry bodies into a small block tree and renders
+// that tree back to Markdown. Render is the inverse of Parse: a body that
+// is already in the form Render writes comes back byte for byte, which is
+// what keeps a push followed by a pull from changing the body.
+package markdown
+
+import "strings"
+
+// Kind is the type of a block
+type Kind int
+
+const (
+	Paragraph Kind = iota
+	Heading
+	BulletItem
+	NumberedItem
+	ToDo
+	Quote
+	Callout
+	Code
+	Divider
+)
+
+// CalloutTypes are the GitHub alert types read as callouts, e.g. > [!NOTE]
+var CalloutTypes = []string{"NOTE", "TIP", "IMPORTANT", "WARNING", "CAUTION"}
+
+// Block is a block-level element of a body
+type Block struct {
+	Kind     Kind
+	Level    int     // heading level, 1 to 3
+	Checked  bool    // whether a to-do is done
+	Callout  string  // callout type, one of CalloutTypes
+	Language string  // code block language, empty when not given
+	Text     []Span  // for code blocks, a single unstyled span
+	Children []Block // nested blocks, rendered indented under this one
+}
+
+// Span is a run of inline text with one style
+type Span struct {
+	Text      string
+	Bold      bool
+	Italic    bool
+	Strike    bool
+	Underline bool
+	Code      bool
+	Link      string // target URL when the span is a link
+}
+
+// IsListItem reports whether the block is an item of a list. Consecutive
+// list items are written without a blank line between them.
+func (b Block) IsListItem() bool {
+	return b.Kind == BulletItem || b.Kind == NumberedItem || b.Kind == ToDo
+}
+
+// PlainText returns the text of spans without any Markdown
+func PlainText(spans []Span) string {
+	var builder strings.Builder
+	for _, span := range spans {
+		builder.WriteString(span.Text)
+	}
+	return builder.String()
+}
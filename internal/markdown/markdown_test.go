@@ -0,0 +1,241 @@
+package markdown
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Bodies in the form Render writes must survive Parse and Render unchanged
+var roundTripBodies = map[string]string{
+	"paragraph":             "Just one line.",
+	"paragraphs":            "First paragraph.\n\nSecond paragraph.",
+	"soft line breaks":      "Line one\nline two\nline three.",
+	"headings":              "# Title\n\n## Section\n\n### Subsection\n\nText.",
+	"bullet list":           "- one\n- two\n- three",
+	"numbered list":         "1. one\n2. two",
+	"nested list":           "- a\n  - b\n    - c\n  - d\n- e",
+	"mixed nesting":         "1. step\n  - detail\n  - [x] checked\n2. next\n  1. sub\n  2. sub",
+	"nested continuation":   "- a\n  - b\n  more b\n- c",
+	"nested code":           "- run\n  ```sh\n  make\n\n  make test\n  ```",
+	"escaped paren number":  "3\\) not a numbered item",
+	"escaped star bullet":   "\\* not a bullet",
+	"list after text":       "Things to buy:\n\n- milk\n- eggs\n\nThat's all.",
+	"mixed lists":           "- bullet\n1. numbered",
+	"link":                  "Read [the docs](https://example.com/docs) today.",
+	"link only":             "[home](https://example.com)",
+	"brackets":              "Notes [draft] and [1] stay as they are.",
+	"escaped link":          "Not a link: \\[x\\](y)",
+	"escaped heading":       "\\# not a heading",
+	"escaped bullet":        "\\- not a bullet",
+	"escaped number":        "1\\. not a numbered item",
+	"escaped continuation":  "Text\n\\- still text",
+	"backslash":             "C:\\Users\\me",
+	"escaped backslash":     "\\\\\\[x\\](y)",
+	"unicode":               "Kopi ☕ dan 日本語.",
+	"bold":                  "A **bold** word.",
+	"italic":                "An *italic* word.",
+	"bold italic":           "***Both*** at once.",
+	"strike":                "~~Gone~~ now.",
+	"underline":             "An <u>underlined</u> word.",
+	"inline code":           "Run `go test` first.",
+	"code with backtick":    "Type ``a`b`` here.",
+	"nested emphasis":       "**bold *and italic* text**",
+	"nested closing":        "**bold *italic***",
+	"styled link":           "[**bold** link](https://example.com)",
+	"styles in heading":     "## A *styled* ~~heading~~",
+	"snake case":            "call some_func_name here",
+	"lone stars":            "2 * 3 * 4 = 24",
+	"escaped stars":         "\\*not italic\\*",
+	"code":                  "Before:\n\n```go\nfunc main() {\n\n\tfmt.Println(\"# hi\")\n}\n```\n\nAfter.",
+	"code without language": "```\nplain\n```",
+	"empty code":            "```sh\n```",
+	"code with fence":       "````md\n```go\nx\n```\n````",
+	"quote":                 "> To be\n> or not.",
+	"quote with blank line": "> one\n>\n> two",
+	"styled quote":          "> **Said** *someone*",
+	"callout":               "> [!WARNING]\n> Back up first.",
+	"empty callout":         "> [!TIP]",
+	"escaped callout":       "> \\[!NOTE]",
+	"to-dos":                "- [ ] write\n- [x] read\n- plain bullet",
+	"escaped to-do":         "- \\[ ] not a to-do",
+	"divider":               "Above\n\n---\n\nBelow",
+	"escaped divider":       "Text\n\\---",
+	"escaped quote":         "\\> not a quote",
+	"escaped fence":         "\\```",
+	"mixed":                 "# Day\n\n- [x] gym\n\n> [!NOTE]\n> Rest day tomorrow.\n\n---\n\n```\nlog\n```",
+	"empty":                 "",
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, body := range roundTripBodies {
+		t.Run(name, func(t *testing.T) {
+			if rendered := Render(Parse(body)); rendered != body {
+				t.Errorf("Expected %q to round-trip, got %q", body, rendered)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	body := "## Plans\n\n- walk\n- read\nmore about reading\n\nDone."
+
+	expected := []Block{
+		{Kind: Heading, Level: 2, Text: []Span{{Text: "Plans"}}},
+		{Kind: BulletItem, Text: []Span{{Text: "walk"}}},
+		{Kind: BulletItem, Text: []Span{{Text: "read\nmore about reading"}}},
+		{Kind: Paragraph, Text: []Span{{Text: "Done."}}},
+	}
+
+	if blocks := Parse(body); !reflect.DeepEqual(blocks, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, blocks)
+	}
+}
+
+func TestParse_Blocks(t *testing.T) {
+	body := "```go\nx := 1\n\ny := 2\n```\n> [!CAUTION]\n> Hot\n\n- [X] done\n\n***"
+
+	expected := []Block{
+		{Kind: Code, Language: "go", Text: []Span{{Text: "x := 1\n\ny := 2"}}},
+		{Kind: Callout, Callout: "CAUTION", Text: []Span{{Text: "Hot"}}},
+		{Kind: ToDo, Checked: true, Text: []Span{{Text: "done"}}},
+		{Kind: Divider},
+	}
+
+	if blocks := Parse(body); !reflect.DeepEqual(blocks, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, blocks)
+	}
+}
+
+func TestParse_NestedLists(t *testing.T) {
+	body := "* one\n    + two\n        3) three\n    7. four\n+ five"
+
+	expected := []Block{
+		{Kind: BulletItem, Text: []Span{{Text: "one"}}, Children: []Block{
+			{Kind: BulletItem, Text: []Span{{Text: "two"}}, Children: []Block{
+				{Kind: NumberedItem, Text: []Span{{Text: "three"}}},
+			}},
+			{Kind: NumberedItem, Text: []Span{{Text: "four"}}},
+		}},
+		{Kind: BulletItem, Text: []Span{{Text: "five"}}},
+	}
+
+	blocks := Parse(body)
+	if !reflect.DeepEqual(blocks, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, blocks)
+	}
+
+	rendered := "- one\n  - two\n    1. three\n  1. four\n- five"
+	if out := Render(blocks); out != rendered {
+		t.Errorf("Expected %q, got %q", rendered, out)
+	}
+}
+
+func TestParse_BlankLineEndsList(t *testing.T) {
+	blocks := Parse("- a\n\n  - b")
+
+	if len(blocks) != 2 || len(blocks[0].Children) != 0 {
+		t.Errorf("Expected two top-level items, got %+v", blocks)
+	}
+}
+
+func TestRender_NumbersRestartAfterOtherBlocks(t *testing.T) {
+	body := "1. a\n2. b\n- c\n1. d\n\nText\n\n1. e"
+
+	if rendered := Render(Parse("1. a\n1. b\n- c\n5. d\n\nText\n\n9. e")); rendered != body {
+		t.Errorf("Expected %q, got %q", body, rendered)
+	}
+}
+
+func TestParse_UnclosedFence(t *testing.T) {
+	blocks := Parse("```\nno end\n\n# still code")
+
+	if len(blocks) != 1 || blocks[0].Kind != Code {
+		t.Fatalf("Expected a single code block, got %+v", blocks)
+	}
+	if text := PlainText(blocks[0].Text); text != "no end\n\n# still code" {
+		t.Errorf("Expected the rest of the body as code, got %q", text)
+	}
+}
+
+func TestParse_ExtraBlankLines(t *testing.T) {
+	blocks := Parse("\n\nOne.\n\n\n\nTwo.\n\n")
+
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+
+	if rendered := Render(blocks); rendered != "One.\n\nTwo." {
+		t.Errorf("Expected blank lines to be normalized, got %q", rendered)
+	}
+}
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []Span
+	}{
+		{"plain", []Span{{Text: "plain"}}},
+		{"a [link](http://x.y) b", []Span{{Text: "a "}, {Text: "link", Link: "http://x.y"}, {Text: " b"}}},
+		{"[not](a link)", []Span{{Text: "[not](a link)"}}},
+		{"[]()", []Span{{Text: "[]()"}}},
+		{"\\[escaped\\]", []Span{{Text: "[escaped]"}}},
+		{"[a \\] b](u)", []Span{{Text: "a ] b", Link: "u"}}},
+		{"**b** *i* _i_ __b__", []Span{{Text: "b", Bold: true}, {Text: " "}, {Text: "i", Italic: true}, {Text: " "}, {Text: "i", Italic: true}, {Text: " "}, {Text: "b", Bold: true}}},
+		{"***x***", []Span{{Text: "x", Bold: true, Italic: true}}},
+		{"~~s~~ <u>u</u>", []Span{{Text: "s", Strike: true}, {Text: " "}, {Text: "u", Underline: true}}},
+		{"`**not bold**`", []Span{{Text: "**not bold**", Code: true}}},
+		{"*a **b** c*", []Span{{Text: "a ", Italic: true}, {Text: "b", Bold: true, Italic: true}, {Text: " c", Italic: true}}},
+		{"*a*b*", []Span{{Text: "a", Italic: true}, {Text: "b*"}}},
+		{"**[l](u)**", []Span{{Text: "l", Bold: true, Link: "u"}}},
+		{"[**l** x](u)", []Span{{Text: "l", Bold: true, Link: "u"}, {Text: " x", Link: "u"}}},
+		{"**open", []Span{{Text: "**open"}}},
+		{"a ** b **", []Span{{Text: "a ** b **"}}},
+		{"snake_case_name", []Span{{Text: "snake_case_name"}}},
+		{"~single~", []Span{{Text: "~single~"}}},
+		{"\\*\\*x\\*\\*", []Span{{Text: "**x**"}}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if spans := ParseInline(tt.text); !reflect.DeepEqual(spans, tt.expected) {
+			t.Errorf("ParseInline(%q): expected %+v, got %+v", tt.text, tt.expected, spans)
+		}
+	}
+}
+
+func TestRender_Nested(t *testing.T) {
+	blocks := []Block{
+		{Kind: BulletItem, Text: []Span{{Text: "parent"}}, Children: []Block{
+			{Kind: BulletItem, Text: []Span{{Text: "child"}}},
+		}},
+		{Kind: Paragraph, Text: []Span{{Text: "after"}}},
+	}
+
+	expected := "- parent\n  - child\n\nafter"
+	if rendered := Render(blocks); rendered != expected {
+		t.Errorf("Expected %q, got %q", expected, rendered)
+	}
+}
+
+func TestRenderInline_EscapesOnlyWhenNeeded(t *testing.T) {
+	tests := []struct {
+		spans    []Span
+		expected string
+	}{
+		{[]Span{{Text: "[draft]"}}, "[draft]"},
+		{[]Span{{Text: "[x](y)"}}, "\\[x\\](y)"},
+		{[]Span{{Text: "see "}, {Text: "[1]", Link: "u"}}, "see [\\[1\\]](u)"},
+		{[]Span{{Text: "a", Link: "http://x.y/(1)"}}, "[a](http://x.y/(1%29)"},
+		{[]Span{{Text: "2 * 3"}}, "2 * 3"},
+		{[]Span{{Text: "*x*"}}, "\\*x\\*"},
+		{[]Span{{Text: "bold ", Bold: true}, {Text: "text"}}, "**bold** text"},
+		{[]Span{{Text: "a", Bold: true}, {Text: "b", Bold: true, Italic: true}}, "**a*b***"},
+		{[]Span{{Text: "x", Bold: true, Link: "u"}}, "[**x**](u)"},
+	}
+
+	for _, tt := range tests {
+		if rendered := RenderInline(tt.spans); rendered != tt.expected {
+			t.Errorf("RenderInline(%+v): expected %q, got %q", tt.spans, tt.expected, rendered)
+		}
+	}
+}
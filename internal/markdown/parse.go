@@ -0,0 +1,248 @@
+package markdown
+
+import (
+	"strings"
+)
+
+// Parse splits a body into blocks. Blocks are separated by blank lines,
+// except list items, which may follow each other directly. A line that
+// doesn't start a block continues the paragraph or list item before it,
+// and everything between code fences is kept as it is. Blocks indented
+// under a list item become its children.
+func Parse(body string) []Block {
+	var blocks []Block
+
+	// List items that blocks indented deeper than them nest under
+	var parents []parent
+
+	var open *Block
+	var openText []string
+	var openInto *[]Block // where the open block goes once complete
+	var openIndent int    // indentation stripped from its later lines
+	var fence string      // fence that closes the open code block
+
+	flush := func() {
+		if open == nil {
+			return
+		}
+
+		text := strings.Join(openText, "\n")
+		if open.Kind != Code {
+			open.Text = ParseInline(text)
+		} else if text != "" {
+			open.Text = []Span{{Text: text}}
+		}
+
+		*openInto = append(*openInto, *open)
+		if open.IsListItem() {
+			siblings := *openInto
+			parents = append(parents, parent{indent: openIndent, block: &siblings[len(siblings)-1]})
+		}
+
+		open, openText, fence = nil, nil, ""
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		if fence != "" {
+			if isClosingFence(line, fence) {
+				flush()
+			} else {
+				openText = append(openText, trimIndent(line, openIndent))
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			// A blank line ends any list
+			flush()
+			parents = nil
+			continue
+		}
+
+		// Quote lines continue the quote or callout before them
+		if open != nil && (open.Kind == Quote || open.Kind == Callout) {
+			if text, ok := quoteLine(line); ok {
+				openText = append(openText, text)
+				continue
+			}
+		}
+
+		block, text, ok := parseBlockStart(line)
+		if !ok {
+			if open == nil {
+				open, openInto, openIndent = &Block{Kind: Paragraph}, &blocks, 0
+				parents = nil
+			}
+			openText = append(openText, trimIndent(line, openIndent))
+			continue
+		}
+
+		flush()
+
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		into := &blocks
+		if len(parents) > 0 {
+			into = &parents[len(parents)-1].block.Children
+		}
+
+		switch block.Kind {
+		case Heading:
+			// Headings are a single line
+			block.Text = ParseInline(text)
+			*into = append(*into, block)
+			continue
+		case Divider:
+			*into = append(*into, block)
+			continue
+		case Code:
+			open, openInto, openIndent, fence = &block, into, indent, text
+			continue
+		case Callout:
+			// The [!TYPE] line carries no text
+			open, openInto, openIndent = &block, into, indent
+			continue
+		}
+
+		open, openInto, openIndent = &block, into, indent
+		openText = []string{text}
+	}
+
+	flush()
+	return blocks
+}
+
+// parent is an open list item and the indentation of its marker
+type parent struct {
+	indent int
+	block  *Block
+}
+
+// trimIndent removes up to n characters of leading whitespace
+func trimIndent(line string, n int) string {
+	i := 0
+	for i < n && i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	return line[i:]
+}
+
+// parseBlockStart recognises a line that starts a block other than a
+// paragraph and returns the block with the text that follows its marker.
+// For a code block the text is the opening fence.
+func parseBlockStart(line string) (Block, string, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+
+	for level := 1; level <= 3; level++ {
+		marker := strings.Repeat("#", level) + " "
+		if strings.HasPrefix(trimmed, marker) {
+			return Block{Kind: Heading, Level: level}, trimmed[len(marker):], true
+		}
+	}
+
+	if isDivider(trimmed) {
+		return Block{Kind: Divider}, "", true
+	}
+
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		n := runLength(trimmed, 0)
+		language := strings.TrimSpace(trimmed[n:])
+		// Backticks in the info string mean inline code, not a fence
+		if trimmed[0] != '`' || !strings.Contains(language, "`") {
+			return Block{Kind: Code, Language: language}, trimmed[:n], true
+		}
+	}
+
+	if text, ok := quoteLine(trimmed); ok {
+		if kind := calloutType(text); kind != "" {
+			return Block{Kind: Callout, Callout: kind}, "", true
+		}
+		return Block{Kind: Quote}, text, true
+	}
+
+	if text, ok := bulletItem(trimmed); ok {
+		if checked, task, ok := taskBox(text); ok {
+			return Block{Kind: ToDo, Checked: checked}, task, true
+		}
+		return Block{Kind: BulletItem}, text, true
+	}
+
+	if n := numberMarker(trimmed); n > 0 {
+		return Block{Kind: NumberedItem}, trimmed[n:], true
+	}
+
+	return Block{}, "", false
+}
+
+// bulletItem returns the text after a -, * or + bullet
+func bulletItem(line string) (string, bool) {
+	if len(line) < 2 || strings.IndexByte("-*+", line[0]) < 0 || line[1] != ' ' {
+		return "", false
+	}
+	return line[2:], true
+}
+
+// numberMarker returns the length of an N. or N) marker and the space
+// after it, or 0
+func numberMarker(line string) int {
+	digits := 0
+	for digits < len(line) && digits < 9 && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits+1 >= len(line) {
+		return 0
+	}
+	if (line[digits] != '.' && line[digits] != ')') || line[digits+1] != ' ' {
+		return 0
+	}
+	return digits + 2
+}
+
+// isDivider reports whether line is a thematic break such as ---
+func isDivider(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	if len(line) < 3 || strings.IndexByte("-*_", line[0]) < 0 {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// isClosingFence reports whether line closes a code block opened by fence
+func isClosingFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) >= len(fence) && strings.Count(trimmed, fence[:1]) == len(trimmed)
+}
+
+// quoteLine returns the text of a line starting with >
+func quoteLine(line string) (string, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if !strings.HasPrefix(trimmed, ">") {
+		return "", false
+	}
+	return strings.TrimPrefix(trimmed[1:], " "), true
+}
+
+// calloutType returns the type of a [!TYPE] callout marker, or ""
+func calloutType(text string) string {
+	for _, kind := range CalloutTypes {
+		if strings.TrimRight(text, " \t") == "[!"+kind+"]" {
+			return kind
+		}
+	}
+	return ""
+}
+
+// taskBox matches the [ ] or [x] that starts the text of a to-do
+func taskBox(text string) (bool, string, bool) {
+	for _, box := range []string{"[ ]", "[x]", "[X]"} {
+		if text == box {
+			return box != "[ ]", "", true
+		}
+		if strings.HasPrefix(text, box+" ") {
+			return box != "[ ]", text[len(box)+1:], true
+		}
+	}
+	return false, "", false
+}
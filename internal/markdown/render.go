@@ -0,0 +1,157 @@
+package markdown
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Render writes blocks as Markdown in the form Parse reads back unchanged.
+// Consecutive list items are separated by a newline, anything else by a
+// blank line, and children are indented two spaces per level.
+func Render(blocks []Block) string {
+	var builder strings.Builder
+	renderBlocks(&builder, blocks, 0)
+	return builder.String()
+}
+
+func renderBlocks(builder *strings.Builder, blocks []Block, depth int) {
+	indent := strings.Repeat("  ", depth)
+	number := 0
+
+	for i, block := range blocks {
+		if i > 0 {
+			if blocks[i-1].IsListItem() && block.IsListItem() {
+				builder.WriteString("\n")
+			} else {
+				builder.WriteString("\n\n")
+			}
+		}
+
+		// Numbered items count up through each run of them
+		if block.Kind == NumberedItem {
+			number++
+		} else {
+			number = 0
+		}
+
+		for j, line := range blockLines(block, number) {
+			if j > 0 {
+				builder.WriteString("\n")
+			}
+			if line != "" {
+				builder.WriteString(indent + line)
+			}
+		}
+
+		if len(block.Children) > 0 {
+			builder.WriteString("\n")
+			renderBlocks(builder, block.Children, depth+1)
+		}
+	}
+}
+
+// blockLines writes a block without its children. number is the position
+// of a numbered item in its list.
+func blockLines(block Block, number int) []string {
+	switch block.Kind {
+	case Code:
+		return codeLines(block)
+	case Divider:
+		return []string{"---"}
+	}
+
+	lines := strings.Split(RenderInline(block.Text), "\n")
+
+	if block.Kind == Quote || block.Kind == Callout {
+		// A quote that reads as a callout marker is escaped
+		if block.Kind == Quote && calloutType(lines[0]) != "" {
+			lines[0] = "\\" + lines[0]
+		}
+		for i, line := range lines {
+			if line == "" {
+				lines[i] = ">"
+			} else {
+				lines[i] = "> " + line
+			}
+		}
+		if block.Kind == Callout {
+			header := "> [!" + block.Callout + "]"
+			if len(block.Text) == 0 {
+				return []string{header}
+			}
+			lines = append([]string{header}, lines...)
+		}
+		return lines
+	}
+
+	// Text that would read as a marker of its own is escaped
+	marker := blockMarker(block, number)
+	for i, line := range lines {
+		if i > 0 || marker == "" {
+			lines[i] = escapeBlockStart(line)
+		}
+	}
+	if block.Kind == BulletItem {
+		if _, _, ok := taskBox(lines[0]); ok {
+			lines[0] = "\\" + lines[0]
+		}
+	}
+	lines[0] = marker + lines[0]
+
+	return lines
+}
+
+func blockMarker(block Block, number int) string {
+	switch block.Kind {
+	case Heading:
+		return strings.Repeat("#", block.Level) + " "
+	case BulletItem:
+		return "- "
+	case NumberedItem:
+		return strconv.Itoa(number) + ". "
+	case ToDo:
+		if block.Checked {
+			return "- [x] "
+		}
+		return "- [ ] "
+	}
+	return ""
+}
+
+// codeLines writes a fenced code block. The fence is longer than any
+// backtick run that starts a line of the code.
+func codeLines(block Block) []string {
+	code := PlainText(block.Text)
+	lines := strings.Split(code, "\n")
+
+	fence := "```"
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, fence) {
+			fence = strings.Repeat("`", runLength(trimmed, 0)+1)
+		}
+	}
+
+	result := []string{fence + block.Language}
+	if code != "" {
+		result = append(result, lines...)
+	}
+	return append(result, fence)
+}
+
+// escapeBlockStart backslash-escapes the marker of a line that Parse would
+// otherwise read as the start of a block
+func escapeBlockStart(line string) string {
+	if _, _, ok := parseBlockStart(line); !ok {
+		return line
+	}
+
+	start := len(line) - len(strings.TrimLeft(line, " \t"))
+	if line[start] < '0' || line[start] > '9' {
+		return line[:start] + "\\" + line[start:]
+	}
+
+	// Numbered item: escape the dot or parenthesis after the number
+	dot := start + numberMarker(line[start:]) - 2
+	return line[:dot] + "\\" + line[dot:]
+}
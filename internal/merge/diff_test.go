@@ -0,0 +1,39 @@
+package merge
+
+import (
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	local := "one\ntwo\nthree\nfour\nfive"
+	remote := "one\ntwo\n3\nfour\nfive\nsix"
+
+	expected := "--- local\n+++ remote\n" +
+		"@@ -1,5 +1,6 @@\n" +
+		" one\n two\n-three\n+3\n four\n five\n+six\n"
+
+	diff := UnifiedDiff(local, remote, "local", "remote")
+	if diff != expected {
+		t.Errorf("Expected diff:\n%s\ngot:\n%s", expected, diff)
+	}
+}
+
+func TestUnifiedDiff_SeparateHunks(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl"
+	b := "A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL"
+
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n d\n" +
+		"@@ -9,4 +9,4 @@\n i\n j\n k\n-l\n+L\n"
+
+	diff := UnifiedDiff(a, b, "a", "b")
+	if diff != expected {
+		t.Errorf("Expected diff:\n%s\ngot:\n%s", expected, diff)
+	}
+}
+
+func TestUnifiedDiff_Equal(t *testing.T) {
+	if diff := UnifiedDiff("same\ntext", "same\ntext", "a", "b"); diff != "" {
+		t.Errorf("Expected empty diff for equal text, got %q", diff)
+	}
+}
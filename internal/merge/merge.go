@@ -0,0 +1,157 @@
+package merge
+
+import (
+	"strings"
+)
+
+// Conflict markers written into a body when both sides changed the same lines
+const (
+	MarkerLocal  = "<<<<<<< local"
+	MarkerSep    = "======="
+	MarkerRemote = ">>>>>>> remote"
+)
+
+type Result struct {
+	Text      string
+	Conflicts int // number of overlapping hunks marked in Text
+}
+
+// Merge performs a line-based three-way merge of local and remote against
+// their common ancestor base. Changes on only one side are applied
+// automatically; hunks changed differently on both sides are written with
+// git-style conflict markers.
+func Merge(base, local, remote string) Result {
+	baseLines := splitLines(base)
+	localLines := splitLines(local)
+	remoteLines := splitLines(remote)
+
+	localMatch := matchLines(baseLines, localLines)
+	remoteMatch := matchLines(baseLines, remoteLines)
+
+	var out []string
+	var result Result
+	i, j, k := 0, 0, 0
+
+	for {
+		// Find the next base line kept unchanged on both sides
+		next := i
+		for next < len(baseLines) && (localMatch[next] < 0 || remoteMatch[next] < 0) {
+			next++
+		}
+
+		localEnd, remoteEnd := len(localLines), len(remoteLines)
+		if next < len(baseLines) {
+			localEnd, remoteEnd = localMatch[next], remoteMatch[next]
+		}
+
+		hunk, conflicted := mergeHunk(baseLines[i:next], localLines[j:localEnd], remoteLines[k:remoteEnd])
+		out = append(out, hunk...)
+		if conflicted {
+			result.Conflicts++
+		}
+
+		if next == len(baseLines) {
+			break
+		}
+
+		out = append(out, baseLines[next])
+		i, j, k = next+1, localEnd+1, remoteEnd+1
+	}
+
+	result.Text = strings.Join(out, "\n")
+	return result
+}
+
+// HasConflictMarkers reports whether text still contains unresolved markers
+func HasConflictMarkers(text string) bool {
+	return len(ConflictMarkerLines(text)) > 0
+}
+
+// ConflictMarkerLines returns the 0-based line numbers where unresolved
+// conflicts start
+func ConflictMarkerLines(text string) []int {
+	var lines []int
+	for i, line := range strings.Split(text, "\n") {
+		if line == MarkerLocal {
+			lines = append(lines, i)
+		}
+	}
+	return lines
+}
+
+func mergeHunk(base, local, remote []string) ([]string, bool) {
+	switch {
+	case equal(local, base):
+		return remote, false
+	case equal(remote, base), equal(local, remote):
+		return local, false
+	}
+
+	hunk := []string{MarkerLocal}
+	hunk = append(hunk, local...)
+	hunk = append(hunk, MarkerSep)
+	hunk = append(hunk, remote...)
+	hunk = append(hunk, MarkerRemote)
+	return hunk, true
+}
+
+// matchLines returns, for every line of a, the index of the line it is
+// paired with in b by a longest common subsequence, or -1
+func matchLines(a, b []string) []int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			match[i] = j
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return match
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+package merge
+
+import (
+	"testing"
+)
+
+func TestMerge_NonOverlappingChanges(t *testing.T) {
+	base := "Morning walk.\n\nLunch with Sam.\n\nEvening reading."
+	local := "Morning walk in the rain.\n\nLunch with Sam.\n\nEvening reading."
+	remote := "Morning walk.\n\nLunch with Sam.\n\nEvening reading, finished the book."
+
+	result := Merge(base, local, remote)
+
+	if result.Conflicts != 0 {
+		t.Errorf("Expected no conflicts, got %d", result.Conflicts)
+	}
+
+	expected := "Morning walk in the rain.\n\nLunch with Sam.\n\nEvening reading, finished the book."
+	if result.Text != expected {
+		t.Errorf("Expected %q, got %q", expected, result.Text)
+	}
+}
+
+func TestMerge_Insertions(t *testing.T) {
+	base := "one\nthree"
+	local := "zero\none\nthree"
+	remote := "one\ntwo\nthree\nfour"
+
+	result := Merge(base, local, remote)
+
+	if result.Conflicts != 0 {
+		t.Errorf("Expected no conflicts, got %d", result.Conflicts)
+	}
+
+	if result.Text != "zero\none\ntwo\nthree\nfour" {
+		t.Errorf("Expected both insertions, got %q", result.Text)
+	}
+}
+
+func TestMerge_SameChangeOnBothSides(t *testing.T) {
+	result := Merge("old line", "new line", "new line")
+
+	if result.Conflicts != 0 || result.Text != "new line" {
+		t.Errorf("Expected identical edits to merge cleanly, got %+v", result)
+	}
+}
+
+func TestMerge_Deletion(t *testing.T) {
+	result := Merge("keep\ndrop\nkeep too", "keep\nkeep too", "keep\ndrop\nkeep too")
+
+	if result.Conflicts != 0 || result.Text != "keep\nkeep too" {
+		t.Errorf("Expected deletion to apply, got %+v", result)
+	}
+}
+
+func TestMerge_OverlappingChanges(t *testing.T) {
+	base := "Intro.\nThe weather was fine.\nOutro."
+	local := "Intro.\nThe weather was sunny.\nOutro."
+	remote := "Intro.\nThe weather was cloudy.\nOutro."
+
+	result := Merge(base, local, remote)
+
+	if result.Conflicts != 1 {
+		t.Fatalf("Expected 1 conflict, got %d", result.Conflicts)
+	}
+
+	expected := "Intro.\n" +
+		"<<<<<<< local\nThe weather was sunny.\n=======\nThe weather was cloudy.\n>>>>>>> remote\n" +
+		"Outro."
+	if result.Text != expected {
+		t.Errorf("Expected %q, got %q", expected, result.Text)
+	}
+
+	if !HasConflictMarkers(result.Text) {
+		t.Error("Expected conflict markers to be detected")
+	}
+
+	lines := ConflictMarkerLines(result.Text)
+	if len(lines) != 1 || lines[0] != 1 {
+		t.Errorf("Expected conflict to start on line 1, got %v", lines)
+	}
+}
+
+func TestMerge_NoBase(t *testing.T) {
+	result := Merge("", "local text", "remote text")
+
+	if result.Conflicts != 1 {
+		t.Errorf("Expected unrelated bodies to conflict, got %+v", result)
+	}
+
+	result = Merge("", "same text", "same text")
+	if result.Conflicts != 0 || result.Text != "same text" {
+		t.Errorf("Expected identical bodies to merge cleanly, got %+v", result)
+	}
+}
+
+func TestHasConflictMarkers_PlainText(t *testing.T) {
+	if HasConflictMarkers("Just a normal entry.\n=======\nwith a rule") {
+		t.Error("Expected no conflict markers in plain text")
+	}
+}
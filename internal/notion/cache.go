@@ -0,0 +1,86 @@
+package notion
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pageCache keeps the rendered Markdown of pages on disk, keyed by their
+// last-edited time, so a page that didn't change is never fetched twice.
+// A nil cache keeps nothing.
+type pageCache struct {
+	dir string
+}
+
+type cachedPage struct {
+	LastEditedTime time.Time `json:"last_edited_time"`
+	CachedAt       time.Time `json:"cached_at"`
+	Markdown       string    `json:"markdown"`
+}
+
+// get returns the cached Markdown of page, if it was cached at its
+// current last-edited time. Notion rounds that time down to the minute,
+// so a body cached within the minute it was edited is not trusted: a
+// later edit in the same minute wouldn't change the key.
+func (c *pageCache) get(page Page) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+
+	data, err := os.ReadFile(c.path(page.ID))
+	if err != nil {
+		return "", false
+	}
+
+	var cached cachedPage
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return "", false
+	}
+
+	if !cached.LastEditedTime.Equal(page.LastEditedTime) || cached.CachedAt.Before(page.LastEditedTime.Add(time.Minute)) {
+		return "", false
+	}
+	return cached.Markdown, true
+}
+
+// put caches the Markdown of page. The cache is only an optimisation, so
+// failing to write it is not an error.
+func (c *pageCache) put(page Page, markdown string) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(cachedPage{
+		LastEditedTime: page.LastEditedTime,
+		CachedAt:       time.Now().UTC(),
+		Markdown:       markdown,
+	})
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+
+	tmp := c.path(page.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, c.path(page.ID)); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// remove drops the cached Markdown of a page
+func (c *pageCache) remove(pageID string) {
+	if c != nil {
+		os.Remove(c.path(pageID))
+	}
+}
+
+func (c *pageCache) path(pageID string) string {
+	return filepath.Join(c.dir, pageID+".json")
+}
@@ -3,10 +3,16 @@ package notion
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/ahmaruff/hfl/internal/markdown"
 )
 
 const (
@@ -14,22 +20,47 @@ const (
 	APIVersion = "2022-06-28"
 )
 
+// maxPageSize is the largest page_size Notion accepts on paginated endpoints
+const maxPageSize = 100
+
+// maxBlocksPerRequest is the most blocks Notion accepts in one children
+// array. A request may also nest blocks at most two levels deep.
+const maxBlocksPerRequest = 100
+
 type Client struct {
-	token  string
-	client *http.Client
+	token   string
+	baseURL string
+	client  *http.Client
+	limiter *rateLimiter
+	options ClientOptions
 }
 
 func NewClient(token string) *Client {
+	return NewClientWithOptions(token, ClientOptions{})
+}
+
+// NewClientWithOptions creates a client with custom rate limit and retry
+// settings. Zero-valued options fall back to the defaults.
+func NewClientWithOptions(token string, options ClientOptions) *Client {
+	options = options.withDefaults()
+
 	return &Client{
-		token: token,
+		token:   token,
+		baseURL: BaseURL,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		limiter: newRateLimiter(options.RequestsPerSecond),
+		options: options,
 	}
 }
 
+// makeRequest sends a request through the shared rate limiter, retrying
+// transient failures with exponential backoff. Requests that create
+// objects or append blocks are only retried when Notion rejected them
+// outright (429), so a retry can never create the same page or append
+// the same blocks twice.
 func (c *Client) makeRequest(method, url string, body interface{}) (*http.Response, error) {
-	var bodyReader io.Reader
 	var jsonData []byte
 	var err error
 
@@ -40,11 +71,57 @@ func (c *Client) makeRequest(method, url string, body interface{}) (*http.Respon
 		}
 
 		// fmt.Printf("📤 Request Body to %s %s:\n%s\n", method, url, string(jsonData))
+	}
+
+	idempotent := isIdempotent(method, url)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doRequest(method, url, jsonData)
+		if err == nil {
+			return resp, nil
+		}
+
+		retryable := IsRetryable(err)
+		var apiErr *APIError
+		if !idempotent && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests) {
+			retryable = false
+		}
+
+		if !retryable || attempt >= *c.options.MaxRetries {
+			return nil, err
+		}
+
+		delay, ok := retryAfter(resp)
+		if !ok {
+			delay = backoff(attempt, c.options.BaseDelay, c.options.MaxDelay)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// isIdempotent reports whether sending a request twice has the same effect
+// as sending it once. Queries and searches are POSTs that only read;
+// appending children is a PATCH that adds blocks every time.
+func isIdempotent(method, url string) bool {
+	switch method {
+	case "POST":
+		return strings.HasSuffix(url, "/query") || url == "/search"
+	case "PATCH":
+		return !strings.HasSuffix(url, "/children")
+	}
+	return true
+}
 
+// doRequest sends a single request. On an API error it returns the
+// (already closed) response alongside the error so headers such as
+// Retry-After can still be read.
+func (c *Client) doRequest(method, url string, jsonData []byte) (*http.Response, error) {
+	var bodyReader io.Reader
+	if jsonData != nil {
 		bodyReader = bytes.NewReader(jsonData)
 	}
 
-	req, err := http.NewRequest(method, BaseURL+url, bodyReader)
+	req, err := http.NewRequest(method, c.baseURL+url, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -53,6 +130,8 @@ func (c *Client) makeRequest(method, url string, body interface{}) (*http.Respon
 	req.Header.Set("Notion-Version", APIVersion)
 	req.Header.Set("Content-Type", "application/json")
 
+	c.limiter.Wait()
+
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
@@ -63,17 +142,66 @@ func (c *Client) makeRequest(method, url string, body interface{}) (*http.Respon
 		errorBody, _ := io.ReadAll(resp.Body)
 
 		// fmt.Printf("❌ API Error Response:\n%s\n", string(errorBody))
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(errorBody))
+		apiErr := &APIError{}
+		if json.Unmarshal(errorBody, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = string(errorBody)
+		}
+		apiErr.StatusCode = resp.StatusCode
+
+		return resp, apiErr
 	}
 
 	return resp, nil
 }
 
-func (c *Client) QueryDatabase(databaseID string, filter interface{}) (*QueryResponse, error) {
-	body := map[string]interface{}{}
-	if filter != nil {
-		body["filter"] = filter
+// QueryDatabase returns every page in the database matching query,
+// following start_cursor until Notion reports has_more = false.
+func (c *Client) QueryDatabase(databaseID string, query Query) (*QueryResponse, error) {
+	var result QueryResponse
+
+	err := c.QueryDatabaseFunc(databaseID, query, func(page Page) error {
+		result.Results = append(result.Results, page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// QueryDatabaseFunc streams every page matching query to fn, one result
+// page at a time, so large databases never have to be held in memory.
+// Returning an error from fn stops the iteration and returns that error.
+func (c *Client) QueryDatabaseFunc(databaseID string, query Query, fn func(Page) error) error {
+	cursor := ""
+
+	for {
+		response, err := c.queryDatabasePage(databaseID, query, cursor)
+		if err != nil {
+			return err
+		}
+
+		for _, page := range response.Results {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+
+		if !response.HasMore || response.NextCursor == "" {
+			return nil
+		}
+		cursor = response.NextCursor
 	}
+}
+
+// queryDatabasePage fetches a single page of query results starting at cursor
+func (c *Client) queryDatabasePage(databaseID string, query Query, cursor string) (*QueryResponse, error) {
+	body := struct {
+		Query
+		PageSize    int    `json:"page_size"`
+		StartCursor string `json:"start_cursor,omitempty"`
+	}{query, maxPageSize, cursor}
 
 	resp, err := c.makeRequest("POST", "/databases/"+databaseID+"/query", body)
 	if err != nil {
@@ -103,6 +231,89 @@ func (c *Client) GetDatabase(databaseID string) (map[string]interface{}, error)
 	return result, nil
 }
 
+// CreateDatabase creates a database with the given properties as a child
+// of a page
+func (c *Client) CreateDatabase(parentPageID, title string, properties map[string]interface{}) (*Database, error) {
+	body := map[string]interface{}{
+		"parent": map[string]string{
+			"type":    "page_id",
+			"page_id": parentPageID,
+		},
+		"title":      richText([]markdown.Span{{Text: title}}),
+		"properties": properties,
+	}
+
+	resp, err := c.makeRequest("POST", "/databases", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result Database
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode database: %w", err)
+	}
+	return &result, nil
+}
+
+// SearchDatabases returns every live database shared with the integration
+// whose title matches query; an empty query matches all of them
+func (c *Client) SearchDatabases(query string) ([]Database, error) {
+	var databases []Database
+	cursor := ""
+
+	for {
+		body := map[string]interface{}{
+			"filter":    map[string]string{"property": "object", "value": "database"},
+			"page_size": maxPageSize,
+		}
+		if query != "" {
+			body["query"] = query
+		}
+		if cursor != "" {
+			body["start_cursor"] = cursor
+		}
+
+		resp, err := c.makeRequest("POST", "/search", body)
+		if err != nil {
+			return nil, err
+		}
+
+		var result SearchResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode search results: %w", err)
+		}
+
+		for _, database := range result.Results {
+			if !database.Archived && !database.InTrash {
+				databases = append(databases, database)
+			}
+		}
+
+		if !result.HasMore || result.NextCursor == "" {
+			return databases, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+// GetMe returns the bot user of the token, which checks the token is valid
+func (c *Client) GetMe() (*User, error) {
+	resp, err := c.makeRequest("GET", "/users/me", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result User
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode user: %w", err)
+	}
+	return &result, nil
+}
+
 func (c *Client) UpdateDatabase(databaseID string, properties map[string]interface{}) error {
 	body := map[string]interface{}{
 		"properties": properties,
@@ -123,8 +334,14 @@ func (c *Client) CreatePage(databaseID string, properties Properties, children [
 		"properties": properties,
 	}
 
-	if len(children) > 0 {
-		body["children"] = children
+	// The page is created with the first batch of blocks; the rest
+	// follow once it exists
+	first, rest := children, []Block(nil)
+	if len(children) > maxBlocksPerRequest {
+		first, rest = children[:maxBlocksPerRequest], children[maxBlocksPerRequest:]
+	}
+	if len(first) > 0 {
+		body["children"] = requestBlocks(first)
 	}
 
 	resp, err := c.makeRequest("POST", "/pages", body)
@@ -138,6 +355,35 @@ func (c *Client) CreatePage(databaseID string, properties Properties, children [
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
+	if hasHeldBack(first) {
+		created, err := c.GetBlockChildren(result.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add content to page %s: %w", result.ID, err)
+		}
+		if err := c.appendHeldBack(first, created.Results); err != nil {
+			return nil, fmt.Errorf("failed to add content to page %s: %w", result.ID, err)
+		}
+	}
+
+	if err := c.AppendBlockChildren(result.ID, rest); err != nil {
+		return nil, fmt.Errorf("failed to add content to page %s: %w", result.ID, err)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) GetPage(pageID string) (*Page, error) {
+	resp, err := c.makeRequest("GET", "/pages/"+pageID, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result Page
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
 	return &result, nil
 }
 
@@ -160,8 +406,76 @@ func (c *Client) UpdatePage(pageID string, properties Properties) (*Page, error)
 	return &result, nil
 }
 
+// ArchivePage moves a page to the trash in Notion
+func (c *Client) ArchivePage(pageID string) error {
+	body := map[string]interface{}{
+		"archived": true,
+	}
+
+	resp, err := c.makeRequest("PATCH", "/pages/"+pageID, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// GetBlockChildren returns every direct child of a block, following
+// start_cursor until Notion reports has_more = false.
 func (c *Client) GetBlockChildren(blockID string) (*BlockListResponse, error) {
-	resp, err := c.makeRequest("GET", "/blocks/"+blockID+"/children", nil)
+	var result BlockListResponse
+	cursor := ""
+
+	for {
+		response, err := c.getBlockChildrenPage(blockID, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Results = append(result.Results, response.Results...)
+
+		if !response.HasMore || response.NextCursor == "" {
+			return &result, nil
+		}
+		cursor = response.NextCursor
+	}
+}
+
+// GetBlockTree returns the children of a block with every nested block
+// fetched as well, to any depth. Nested blocks are attached to the
+// Children field of their parent's content.
+func (c *Client) GetBlockTree(blockID string) ([]Block, error) {
+	response, err := c.GetBlockChildren(blockID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := response.Results
+	for i := range blocks {
+		if !blocks[i].HasChildren {
+			continue
+		}
+
+		children, err := c.GetBlockTree(blocks[i].ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get children of block %s: %w", blocks[i].ID, err)
+		}
+
+		blocks[i].setChildren(children)
+	}
+
+	return blocks, nil
+}
+
+// getBlockChildrenPage fetches a single page of children starting at cursor
+func (c *Client) getBlockChildrenPage(blockID, cursor string) (*BlockListResponse, error) {
+	query := url.Values{}
+	query.Set("page_size", strconv.Itoa(maxPageSize))
+	if cursor != "" {
+		query.Set("start_cursor", cursor)
+	}
+
+	resp, err := c.makeRequest("GET", "/blocks/"+blockID+"/children?"+query.Encode(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -175,24 +489,234 @@ func (c *Client) GetBlockChildren(blockID string) (*BlockListResponse, error) {
 	return &result, nil
 }
 
+// UpdateBlockChildren makes the children of a block match children. Only
+// blocks that changed are touched: unchanged blocks keep their IDs and
+// comments, changed ones are patched in place where the type allows, and
+// the rest are inserted or deleted. Deletes come last, and the first one
+// that fails stops the update.
 func (c *Client) UpdateBlockChildren(blockID string, children []Block) error {
 	existing, err := c.GetBlockChildren(blockID)
 	if err != nil {
 		return err
 	}
 
-	for _, block := range existing.Results {
-		c.makeRequest("DELETE", "/blocks/"+block.ID, nil)
+	steps, deletes := diffBlocks(existing.Results, children)
+
+	after := ""
+	for i := 0; i < len(steps); {
+		step := steps[i]
+
+		if step.existing == nil {
+			// Consecutive new blocks go in together
+			var run []Block
+			for i < len(steps) && steps[i].existing == nil {
+				run = append(run, steps[i].block)
+				i++
+			}
+
+			created, err := c.appendBlocks(blockID, after, run)
+			if err != nil {
+				return err
+			}
+			if len(created) > 0 {
+				after = created[len(created)-1].ID
+			}
+			continue
+		}
+
+		if step.patch {
+			if err := c.UpdateBlock(step.existing.ID, step.block); err != nil {
+				return fmt.Errorf("failed to update block %s: %w", step.existing.ID, err)
+			}
+		}
+		if step.existing.HasChildren || len(step.block.Children()) > 0 {
+			if err := c.UpdateBlockChildren(step.existing.ID, step.block.Children()); err != nil {
+				return err
+			}
+		}
+
+		after = step.existing.ID
+		i++
 	}
 
-	if len(children) > 0 {
-		body := map[string]interface{}{
-			"children": children,
+	for _, block := range deletes {
+		if err := c.DeleteBlock(block.ID); err != nil {
+			return fmt.Errorf("failed to delete block %s: %w", block.ID, err)
 		}
+	}
+
+	return nil
+}
+
+// UpdateBlock replaces the content of a block, keeping its ID and
+// children. The type of a block can't be changed.
+func (c *Client) UpdateBlock(blockID string, block Block) error {
+	data, err := json.Marshal(block.withChildren(nil))
+	if err != nil {
+		return fmt.Errorf("failed to marshal block: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("failed to marshal block: %w", err)
+	}
+
+	body := map[string]interface{}{
+		block.Type: fields[block.Type],
+	}
+
+	resp, err := c.makeRequest("PATCH", "/blocks/"+blockID, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
 
-		_, err := c.makeRequest("PATCH", "/blocks/"+blockID+"/children", body)
+// DeleteBlock moves a block, and everything nested under it, to the trash
+func (c *Client) DeleteBlock(blockID string) error {
+	resp, err := c.makeRequest("DELETE", "/blocks/"+blockID, nil)
+	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// AppendBlockChildren adds blocks after the existing children of a block.
+// Notion takes at most 100 blocks, nested two levels deep, per request, so
+// longer lists go in batches and deeper blocks follow once their parents
+// exist.
+func (c *Client) AppendBlockChildren(blockID string, children []Block) error {
+	_, err := c.appendBlocks(blockID, "", children)
+	return err
+}
+
+// appendBlocks inserts blocks under blockID right after the child with ID
+// after, or at the end when after is empty, and returns the top-level
+// blocks Notion created
+func (c *Client) appendBlocks(blockID, after string, children []Block) ([]Block, error) {
+	var created []Block
+
+	for start := 0; start < len(children); start += maxBlocksPerRequest {
+		batch := children[start:min(start+maxBlocksPerRequest, len(children))]
+
+		body := map[string]interface{}{
+			"children": requestBlocks(batch),
+		}
+		if after != "" {
+			body["after"] = after
+		}
+
+		resp, err := c.makeRequest("PATCH", "/blocks/"+blockID+"/children", body)
+		if err != nil {
+			return nil, err
+		}
+
+		var result BlockListResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		if err := c.appendHeldBack(batch, result.Results); err != nil {
+			return nil, err
+		}
+
+		created = append(created, result.Results...)
+		if n := len(result.Results); n > 0 {
+			after = result.Results[n-1].ID
+		}
+	}
+
+	return created, nil
+}
+
+// requestBlocks returns blocks as they can be sent in one request: nested
+// blocks below the second level, and children arrays too long for one
+// request, are held back
+func requestBlocks(blocks []Block) []Block {
+	sent := make([]Block, len(blocks))
+
+	for i, block := range blocks {
+		children := block.Children()
+		if len(children) > maxBlocksPerRequest {
+			sent[i] = block.withChildren(nil)
+			continue
+		}
+
+		if len(children) > 0 {
+			nested := make([]Block, len(children))
+			for j, child := range children {
+				nested[j] = child.withChildren(nil)
+			}
+			sent[i] = block.withChildren(nested)
+			continue
+		}
+
+		sent[i] = block
+	}
+
+	return sent
+}
+
+// hasHeldBack reports whether requestBlocks holds back any of blocks'
+// nested blocks
+func hasHeldBack(blocks []Block) bool {
+	for _, block := range blocks {
+		children := block.Children()
+		if len(children) > maxBlocksPerRequest {
+			return true
+		}
+		for _, child := range children {
+			if len(child.Children()) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// appendHeldBack sends the nested blocks requestBlocks held back, under
+// the blocks Notion created for them
+func (c *Client) appendHeldBack(blocks, created []Block) error {
+	if !hasHeldBack(blocks) {
+		return nil
+	}
+	if len(created) < len(blocks) {
+		return fmt.Errorf("expected %d created blocks, Notion returned %d", len(blocks), len(created))
+	}
+
+	for i, block := range blocks {
+		if !hasHeldBack(blocks[i : i+1]) {
+			continue
+		}
+
+		children := block.Children()
+		if len(children) > maxBlocksPerRequest {
+			if err := c.AppendBlockChildren(created[i].ID, children); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// The children went with their parent; their own children follow
+
+		createdChildren, err := c.GetBlockChildren(created[i].ID)
+		if err != nil {
+			return err
+		}
+		if len(createdChildren.Results) < len(children) {
+			return fmt.Errorf("expected %d created blocks, Notion returned %d", len(children), len(createdChildren.Results))
+		}
+
+		for j, child := range children {
+			if err := c.AppendBlockChildren(createdChildren.Results[j].ID, child.Children()); err != nil {
+				return err
+			}
+		}
+	}
 
 	return nil
 }
@@ -2,8 +2,20 @@
 package notion
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ahmaruff/hfl/internal/markdown"
 )
 
 func TestClient_QueryDatabase(t *testing.T) {
@@ -16,10 +28,622 @@ func TestClient_QueryDatabase(t *testing.T) {
 
 	client := NewClient(token)
 
-	response, err := client.QueryDatabase(dbID, nil)
+	response, err := client.QueryDatabase(dbID, Query{})
 	if err != nil {
 		t.Fatalf("QueryDatabase failed: %v", err)
 	}
 
 	t.Logf("Found %d pages", len(response.Results))
 }
+
+// newTestClient returns a client that talks to the given fake server
+func newTestClient(server *httptest.Server) *Client {
+	client := NewClientWithOptions("test-token", ClientOptions{RequestsPerSecond: 1000})
+	client.baseURL = server.URL
+	return client
+}
+
+// fakeQueryServer serves the given result pages in order, one per request,
+// and records the request bodies it received
+func fakeQueryServer(t *testing.T, pages [][]Page) (*httptest.Server, *[]map[string]interface{}) {
+	var requests []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/databases/db-1/query" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		requests = append(requests, body)
+
+		index := 0
+		if cursor, ok := body["start_cursor"].(string); ok {
+			index, _ = strconv.Atoi(cursor)
+		}
+
+		response := QueryResponse{Results: pages[index]}
+		if index+1 < len(pages) {
+			response.HasMore = true
+			response.NextCursor = strconv.Itoa(index + 1)
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}))
+
+	return server, &requests
+}
+
+func TestQueryDatabase_FollowsCursor(t *testing.T) {
+	server, requests := fakeQueryServer(t, [][]Page{
+		{{ID: "page-1"}, {ID: "page-2"}},
+		{{ID: "page-3"}, {ID: "page-4"}},
+		{{ID: "page-5"}},
+	})
+	defer server.Close()
+
+	response, err := newTestClient(server).QueryDatabase("db-1", Query{})
+	if err != nil {
+		t.Fatalf("QueryDatabase failed: %v", err)
+	}
+
+	if len(response.Results) != 5 {
+		t.Fatalf("Expected 5 pages, got %d", len(response.Results))
+	}
+
+	for i, page := range response.Results {
+		expected := "page-" + strconv.Itoa(i+1)
+		if page.ID != expected {
+			t.Errorf("Expected page %d to be %s, got %s", i, expected, page.ID)
+		}
+	}
+
+	if response.HasMore {
+		t.Error("Expected HasMore to be false after following all cursors")
+	}
+
+	if len(*requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(*requests))
+	}
+
+	if _, ok := (*requests)[0]["start_cursor"]; ok {
+		t.Error("Expected first request to have no start_cursor")
+	}
+
+	if (*requests)[2]["start_cursor"] != "2" {
+		t.Errorf("Expected third request to use cursor 2, got %v", (*requests)[2]["start_cursor"])
+	}
+}
+
+func TestQueryDatabase_SendsFilter(t *testing.T) {
+	server, requests := fakeQueryServer(t, [][]Page{{{ID: "page-1"}}})
+	defer server.Close()
+
+	since := time.Date(2025, 8, 16, 10, 0, 0, 0, time.FixedZone("WIB", 7*3600))
+	query := Query{
+		Filter: And(EditedSince(since), TextEquals("HFL_Date", "2025-08-16")),
+		Sorts:  []Sort{SortByEdited(Ascending)},
+	}
+	if _, err := newTestClient(server).QueryDatabase("db-1", query); err != nil {
+		t.Fatalf("QueryDatabase failed: %v", err)
+	}
+
+	sent, _ := json.Marshal(map[string]interface{}{"filter": (*requests)[0]["filter"], "sorts": (*requests)[0]["sorts"]})
+	expected := `{"filter":{"and":[` +
+		`{"last_edited_time":{"on_or_after":"2025-08-16T03:00:00Z"},"timestamp":"last_edited_time"},` +
+		`{"property":"HFL_Date","rich_text":{"equals":"2025-08-16"}}]},` +
+		`"sorts":[{"direction":"ascending","timestamp":"last_edited_time"}]}`
+	if string(sent) != expected {
+		t.Errorf("Expected %s, got %s", expected, sent)
+	}
+}
+
+func TestQueryDatabaseFunc_Streams(t *testing.T) {
+	server, requests := fakeQueryServer(t, [][]Page{
+		{{ID: "page-1"}, {ID: "page-2"}},
+		{{ID: "page-3"}},
+	})
+	defer server.Close()
+
+	var seen []string
+	err := newTestClient(server).QueryDatabaseFunc("db-1", Query{}, func(page Page) error {
+		seen = append(seen, page.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("QueryDatabaseFunc failed: %v", err)
+	}
+
+	if strings.Join(seen, ",") != "page-1,page-2,page-3" {
+		t.Errorf("Expected all pages in order, got %v", seen)
+	}
+
+	if len(*requests) != 2 {
+		t.Errorf("Expected 2 requests, got %d", len(*requests))
+	}
+}
+
+func TestQueryDatabaseFunc_StopsOnCallbackError(t *testing.T) {
+	server, requests := fakeQueryServer(t, [][]Page{
+		{{ID: "page-1"}, {ID: "page-2"}},
+		{{ID: "page-3"}},
+	})
+	defer server.Close()
+
+	stop := errors.New("stop")
+	err := newTestClient(server).QueryDatabaseFunc("db-1", Query{}, func(page Page) error {
+		if page.ID == "page-2" {
+			return stop
+		}
+		return nil
+	})
+
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected callback error to be returned, got %v", err)
+	}
+
+	if len(*requests) != 1 {
+		t.Errorf("Expected iteration to stop after first request, got %d requests", len(*requests))
+	}
+}
+
+// fakeBlockServer serves block children from a map of block ID to result
+// pages, honouring start_cursor, and counts the requests per block
+func fakeBlockServer(t *testing.T, children map[string][][]Block) (*httptest.Server, map[string]int) {
+	requests := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if r.Method != "GET" || len(parts) != 3 || parts[0] != "blocks" || parts[2] != "children" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		blockID := parts[1]
+		requests[blockID]++
+
+		pages, ok := children[blockID]
+		if !ok {
+			t.Errorf("Unexpected children request for block %s", blockID)
+			json.NewEncoder(w).Encode(BlockListResponse{})
+			return
+		}
+
+		index := 0
+		if cursor := r.URL.Query().Get("start_cursor"); cursor != "" {
+			index, _ = strconv.Atoi(cursor)
+		}
+
+		response := BlockListResponse{Results: pages[index]}
+		if index+1 < len(pages) {
+			response.HasMore = true
+			response.NextCursor = strconv.Itoa(index + 1)
+		}
+
+		json.NewEncoder(w).Encode(response)
+	}))
+
+	return server, requests
+}
+
+func testBlock(id, blockType, text string, hasChildren bool) Block {
+	block := Block{ID: id, Type: blockType, HasChildren: hasChildren}
+	richText := []TextObject{{Type: "text", Text: &Text{Content: text}}}
+
+	switch blockType {
+	case "paragraph":
+		block.Paragraph = &ParagraphBlock{RichText: richText}
+	case "bulleted_list_item":
+		block.BulletedListItem = &ListItemBlock{RichText: richText}
+	case "toggle":
+		block.Toggle = &ToggleBlock{RichText: richText}
+	}
+
+	return block
+}
+
+func TestGetBlockChildren_FollowsCursor(t *testing.T) {
+	server, requests := fakeBlockServer(t, map[string][][]Block{
+		"page-1": {
+			{testBlock("b1", "paragraph", "one", false)},
+			{testBlock("b2", "paragraph", "two", false)},
+			{testBlock("b3", "paragraph", "three", false)},
+		},
+	})
+	defer server.Close()
+
+	response, err := newTestClient(server).GetBlockChildren("page-1")
+	if err != nil {
+		t.Fatalf("GetBlockChildren failed: %v", err)
+	}
+
+	if len(response.Results) != 3 {
+		t.Errorf("Expected 3 blocks, got %d", len(response.Results))
+	}
+
+	if requests["page-1"] != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests["page-1"])
+	}
+}
+
+func TestGetBlockChildren_EscapesCursor(t *testing.T) {
+	cursor := "a+b/c=&d"
+	var received []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.URL.Query().Get("start_cursor"))
+
+		response := BlockListResponse{Results: []Block{testBlock("b1", "paragraph", "one", false)}}
+		if len(received) == 1 {
+			response.HasMore = true
+			response.NextCursor = cursor
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	if _, err := newTestClient(server).GetBlockChildren("page-1"); err != nil {
+		t.Fatalf("GetBlockChildren failed: %v", err)
+	}
+
+	if len(received) != 2 || received[1] != cursor {
+		t.Errorf("Expected the cursor %q to arrive intact, got %q", cursor, received)
+	}
+}
+
+func TestGetBlockTree_Nested(t *testing.T) {
+	server, requests := fakeBlockServer(t, map[string][][]Block{
+		"page-1": {
+			{testBlock("b1", "bulleted_list_item", "parent", true)},
+			{testBlock("b2", "toggle", "toggle", true)},
+		},
+		"b1": {{testBlock("b1-1", "bulleted_list_item", "child", true)}},
+		"b1-1": {
+			{testBlock("b1-1-1", "bulleted_list_item", "grandchild", false)},
+			{testBlock("b1-1-2", "bulleted_list_item", "grandchild 2", false)},
+		},
+		"b2": {{testBlock("b2-1", "paragraph", "hidden", false)}},
+	})
+	defer server.Close()
+
+	blocks, err := newTestClient(server).GetBlockTree("page-1")
+	if err != nil {
+		t.Fatalf("GetBlockTree failed: %v", err)
+	}
+
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 top-level blocks, got %d", len(blocks))
+	}
+
+	child := blocks[0].Children()
+	if len(child) != 1 {
+		t.Fatalf("Expected 1 child, got %d", len(child))
+	}
+
+	if len(child[0].Children()) != 2 {
+		t.Errorf("Expected 2 grandchildren, got %d", len(child[0].Children()))
+	}
+
+	if len(blocks[1].Children()) != 1 {
+		t.Errorf("Expected toggle to have 1 child, got %d", len(blocks[1].Children()))
+	}
+
+	if requests["b1-1-1"] != 0 {
+		t.Error("Expected no request for blocks without children")
+	}
+
+	expected := "- parent\n  - child\n    - grandchild\n    - grandchild 2\n\ntoggle\n  hidden"
+	if content := BlocksToMarkdown(blocks); content != expected {
+		t.Errorf("Expected rendered tree %q, got %q", expected, content)
+	}
+}
+
+func TestCreatePage_BatchesBlocks(t *testing.T) {
+	fake := newFakeNotion(t)
+	client := newTestClient(fake.server)
+
+	var lines []string
+	for i := 0; i < 250; i++ {
+		lines = append(lines, fmt.Sprintf("Paragraph %d.", i))
+	}
+	body := strings.Join(lines, "\n\n")
+
+	page, err := client.CreatePage("db-1", Properties{}, MarkdownToBlocks(body))
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+
+	expected := []string{"POST /pages", "PATCH /blocks/" + page.ID + "/children", "PATCH /blocks/" + page.ID + "/children"}
+	if !reflect.DeepEqual(fake.requests, expected) {
+		t.Fatalf("Expected requests %v, got %v", expected, fake.requests)
+	}
+	if !reflect.DeepEqual(fake.batches, []int{100, 100, 50}) {
+		t.Errorf("Expected batches of 100, 100 and 50 blocks, got %v", fake.batches)
+	}
+
+	blocks, err := client.GetBlockTree(page.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := BlocksToMarkdown(blocks); content != body {
+		t.Error("Expected every block in order")
+	}
+}
+
+func TestCreatePage_DeepNesting(t *testing.T) {
+	fake := newFakeNotion(t)
+	client := newTestClient(fake.server)
+
+	body := "- one\n  - two\n    - three\n      - four\n- five"
+
+	page, err := client.CreatePage("db-1", Properties{}, MarkdownToBlocks(body))
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+
+	// "three" and "four" are below the second level, so they follow in
+	// one append under "two"
+	if n := fake.count("PATCH", "/blocks/"); n != 1 {
+		t.Errorf("Expected 1 append, got %d: %v", n, fake.requests)
+	}
+
+	blocks, err := client.GetBlockTree(page.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := BlocksToMarkdown(blocks); content != body {
+		t.Errorf("Expected %q, got %q", body, content)
+	}
+}
+
+func TestAppendBlockChildren_LongChildList(t *testing.T) {
+	fake := newFakeNotion(t)
+	client := newTestClient(fake.server)
+
+	var lines []string
+	for i := 0; i < 150; i++ {
+		lines = append(lines, fmt.Sprintf("  - item %d", i))
+	}
+	body := "- list\n" + strings.Join(lines, "\n")
+
+	page, err := client.CreatePage("db-1", Properties{}, MarkdownToBlocks(body))
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+
+	// The parent goes alone, then its children in two batches
+	if !reflect.DeepEqual(fake.batches, []int{1, 0, 100, 50}) {
+		t.Errorf("Expected batches of 1, 100 and 50 blocks, got %v (%v)", fake.batches, fake.requests)
+	}
+
+	blocks, err := client.GetBlockTree(page.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := BlocksToMarkdown(blocks); content != body {
+		t.Error("Expected every child in order")
+	}
+}
+
+func TestRichText_SplitsLongText(t *testing.T) {
+	long := strings.Repeat("a", 4500)
+	text := richText([]markdown.Span{{Text: long, Bold: true}})
+
+	if len(text) != 3 {
+		t.Fatalf("Expected 3 rich text objects, got %d", len(text))
+	}
+	for _, object := range text {
+		if len(object.Text.Content) > maxTextLength || !object.Annotations.Bold {
+			t.Errorf("Expected bold pieces of at most %d characters, got %d", maxTextLength, len(object.Text.Content))
+		}
+	}
+
+	if content := BlocksToMarkdown(MarkdownToBlocks(long)); content != long {
+		t.Error("Expected the pieces to join back together")
+	}
+}
+
+func TestSplitText_CountsUTF16(t *testing.T) {
+	// Each emoji is two UTF-16 code units
+	pieces := splitText(strings.Repeat("😀", 1500), maxTextLength)
+
+	if len(pieces) != 2 || utf8.RuneCountInString(pieces[0]) != 1000 {
+		t.Errorf("Expected 1000 emoji in the first piece, got %d pieces", len(pieces))
+	}
+}
+
+// newFastRetryClient returns a test client with negligible backoff delays
+func newFastRetryClient(server *httptest.Server, maxRetries int) *Client {
+	client := NewClientWithOptions("test-token", ClientOptions{
+		RequestsPerSecond: 1000,
+		MaxRetries:        &maxRetries,
+		BaseDelay:         time.Millisecond,
+		MaxDelay:          5 * time.Millisecond,
+	})
+	client.baseURL = server.URL
+	return client
+}
+
+// fakeFlakyServer fails the first failures requests with the given status
+// and Notion error code, then succeeds
+func fakeFlakyServer(failures, status int, code string, headers map[string]string) (*httptest.Server, *int) {
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts <= failures {
+			for key, value := range headers {
+				w.Header().Set(key, value)
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"object":  "error",
+				"status":  status,
+				"code":    code,
+				"message": "try again",
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(Page{ID: "page-1"})
+	}))
+
+	return server, &attempts
+}
+
+func TestMakeRequest_RetriesRateLimit(t *testing.T) {
+	server, attempts := fakeFlakyServer(2, http.StatusTooManyRequests, "rate_limited", map[string]string{"Retry-After": "0"})
+	defer server.Close()
+
+	page, err := newFastRetryClient(server, 3).UpdatePage("page-1", Properties{})
+	if err != nil {
+		t.Fatalf("Expected request to succeed after retries, got %v", err)
+	}
+
+	if page.ID != "page-1" {
+		t.Errorf("Expected page-1, got %s", page.ID)
+	}
+
+	if *attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *attempts)
+	}
+}
+
+func TestMakeRequest_RetriesServerErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		server, attempts := fakeFlakyServer(1, status, "service_unavailable", nil)
+
+		if _, err := newFastRetryClient(server, 3).UpdatePage("page-1", Properties{}); err != nil {
+			t.Errorf("Expected status %d to be retried, got %v", status, err)
+		}
+
+		if *attempts != 2 {
+			t.Errorf("Expected 2 attempts for status %d, got %d", status, *attempts)
+		}
+
+		server.Close()
+	}
+}
+
+func TestMakeRequest_GivesUpAfterMaxRetries(t *testing.T) {
+	server, attempts := fakeFlakyServer(10, http.StatusServiceUnavailable, "service_unavailable", nil)
+	defer server.Close()
+
+	_, err := newFastRetryClient(server, 2).UpdatePage("page-1", Properties{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %v", err)
+	}
+
+	if !IsRetryable(err) {
+		t.Error("Expected 503 to be reported as retryable")
+	}
+
+	if *attempts != 3 {
+		t.Errorf("Expected 1 attempt plus 2 retries, got %d", *attempts)
+	}
+}
+
+func TestMakeRequest_ZeroRetries(t *testing.T) {
+	server, attempts := fakeFlakyServer(1, http.StatusServiceUnavailable, "service_unavailable", nil)
+	defer server.Close()
+
+	if _, err := newFastRetryClient(server, 0).UpdatePage("page-1", Properties{}); err == nil {
+		t.Error("Expected the request to fail without retries")
+	}
+
+	if *attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", *attempts)
+	}
+}
+
+func TestMakeRequest_PermanentErrorNotRetried(t *testing.T) {
+	server, attempts := fakeFlakyServer(10, http.StatusBadRequest, "validation_error", nil)
+	defer server.Close()
+
+	_, err := newFastRetryClient(server, 3).UpdatePage("page-1", Properties{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %v", err)
+	}
+
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "validation_error" {
+		t.Errorf("Expected 400 validation_error, got %d %s", apiErr.StatusCode, apiErr.Code)
+	}
+
+	if IsRetryable(err) {
+		t.Error("Expected validation error to be permanent")
+	}
+
+	if *attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", *attempts)
+	}
+}
+
+func TestMakeRequest_CreateOnlyRetriedWhenRateLimited(t *testing.T) {
+	server, attempts := fakeFlakyServer(1, http.StatusBadGateway, "bad_gateway", nil)
+	defer server.Close()
+
+	if _, err := newFastRetryClient(server, 3).CreatePage("db-1", Properties{}, nil); err == nil {
+		t.Error("Expected create to fail without retrying a 502")
+	}
+
+	if *attempts != 1 {
+		t.Errorf("Expected a single attempt for page creation, got %d", *attempts)
+	}
+
+	server, attempts = fakeFlakyServer(1, http.StatusTooManyRequests, "rate_limited", nil)
+	defer server.Close()
+
+	if _, err := newFastRetryClient(server, 3).CreatePage("db-1", Properties{}, nil); err != nil {
+		t.Errorf("Expected rate-limited create to be retried, got %v", err)
+	}
+
+	if *attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", *attempts)
+	}
+}
+
+func TestMakeRequest_AppendOnlyRetriedWhenRateLimited(t *testing.T) {
+	server, attempts := fakeFlakyServer(1, http.StatusGatewayTimeout, "gateway_timeout", nil)
+	defer server.Close()
+
+	blocks := []Block{NewParagraphBlock("Appended once.")}
+	if err := newFastRetryClient(server, 3).AppendBlockChildren("page-1", blocks); err == nil {
+		t.Error("Expected append to fail without retrying a 504")
+	}
+
+	if *attempts != 1 {
+		t.Errorf("Expected a single attempt for an append, got %d", *attempts)
+	}
+}
+
+func TestRateLimiter_SpacesRequests(t *testing.T) {
+	limiter := newRateLimiter(20) // one request every 50ms
+
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		limiter.Wait()
+	}
+	elapsed := time.Since(start)
+
+	if elapsed < 150*time.Millisecond {
+		t.Errorf("Expected 4 requests to take at least 150ms, took %v", elapsed)
+	}
+}
+
+func TestBackoff_Capped(t *testing.T) {
+	for attempt := 0; attempt < 40; attempt++ {
+		delay := backoff(attempt, 100*time.Millisecond, time.Second)
+		if delay < 0 || delay > time.Second {
+			t.Errorf("Attempt %d: expected delay within [0, 1s], got %v", attempt, delay)
+		}
+	}
+}
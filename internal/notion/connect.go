@@ -0,0 +1,109 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ahmaruff/hfl/internal/config"
+	"github.com/ahmaruff/hfl/internal/markdown"
+)
+
+// defaultTitleName names the title property of a database hfl creates,
+// unless the title field is mapped to another name
+const defaultTitleName = "Name"
+
+// ParseID extracts a Notion ID from a pasted page or database URL, or
+// from a bare ID with or without dashes, and returns it in dashed form
+func ParseID(input string) (string, error) {
+	path := strings.TrimSpace(input)
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimRight(path, "/")
+
+	// URLs end in the title of the page followed by its ID
+	last := path[strings.LastIndex(path, "/")+1:]
+	compact := strings.ReplaceAll(last, "-", "")
+	if len(compact) < 32 || !isHex(compact[len(compact)-32:]) {
+		return "", fmt.Errorf("no Notion ID found in %q", input)
+	}
+
+	id := strings.ToLower(compact[len(compact)-32:])
+	return id[:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:], nil
+}
+
+// isHex reports whether s only has hexadecimal digits
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// PlainTitle returns the title of the database without formatting
+func (d Database) PlainTitle() string {
+	if title := markdown.PlainText(spans(d.Title)); title != "" {
+		return title
+	}
+	return "Untitled"
+}
+
+// DisplayName describes the integration behind a token
+func (u User) DisplayName() string {
+	name := u.Name
+	if name == "" {
+		name = u.ID
+	}
+	if u.Bot != nil && u.Bot.WorkspaceName != "" {
+		return fmt.Sprintf("%s in %s", name, u.Bot.WorkspaceName)
+	}
+	return name
+}
+
+// JournalSchema returns the properties of a new journal database, one
+// for every enabled field
+func JournalSchema(properties config.PropertyMap) map[string]interface{} {
+	schema := make(map[string]interface{})
+	for _, field := range config.PropertyFields {
+		name := properties.Name(field)
+		if field == "title" && (name == "" || name == "title") {
+			// Every database needs a title property
+			name = defaultTitleName
+		}
+		if name != "" {
+			schema[name] = propertySchema(field)
+		}
+	}
+	return schema
+}
+
+// VerifyDatabase fetches a database, explaining the 404 Notion returns
+// for both a wrong ID and a database that isn't shared with the
+// integration
+func (c *Client) VerifyDatabase(databaseID string) (*Database, error) {
+	resp, err := c.makeRequest("GET", "/databases/"+databaseID, nil)
+	if err != nil {
+		return nil, describeDatabaseError(databaseID, err)
+	}
+	defer resp.Body.Close()
+
+	var result Database
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode database: %w", err)
+	}
+	return &result, nil
+}
+
+// describeDatabaseError adds the likely cause to errors fetching a database
+func describeDatabaseError(databaseID string, err error) error {
+	switch {
+	case IsNotFound(err):
+		return fmt.Errorf("database %s not found: check the ID, and share the database with your integration (••• → Connections): %w", databaseID, err)
+	case IsUnauthorized(err):
+		return fmt.Errorf("the API token was rejected; check notion.api_token: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package notion
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ahmaruff/hfl/internal/config"
+)
+
+func TestParseID(t *testing.T) {
+	const id = "0123abcd-4567-89ef-0123-456789abcdef"
+
+	tests := []string{
+		"0123abcd456789ef0123456789abcdef",
+		"0123ABCD-4567-89EF-0123-456789ABCDEF",
+		"https://www.notion.so/workspace/Journal-0123abcd456789ef0123456789abcdef?v=fedcba9876543210fedcba9876543210",
+		"https://www.notion.so/0123abcd456789ef0123456789abcdef",
+		"notion.so/team/My-Daily-Log-0123abcd456789ef0123456789abcdef/",
+		"  0123abcd456789ef0123456789abcdef#section\n",
+	}
+
+	for _, input := range tests {
+		parsed, err := ParseID(input)
+		if err != nil {
+			t.Errorf("ParseID(%q) failed: %v", input, err)
+			continue
+		}
+		if parsed != id {
+			t.Errorf("ParseID(%q): expected %s, got %s", input, id, parsed)
+		}
+	}
+
+	for _, input := range []string{"", "https://www.notion.so/workspace/Journal", "0123abcd456789ef0123456789abcdeg"} {
+		if _, err := ParseID(input); err == nil {
+			t.Errorf("Expected ParseID(%q) to fail", input)
+		}
+	}
+}
+
+func TestSearchDatabases(t *testing.T) {
+	var requests []map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/search" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+
+		if body["start_cursor"] == nil {
+			writeJSON(w, SearchResponse{
+				Results: []Database{{ID: "db-1", Title: richText(nil)}, {ID: "db-old", Archived: true}},
+				HasMore: true, NextCursor: "next",
+			})
+			return
+		}
+		writeJSON(w, SearchResponse{Results: []Database{{ID: "db-2"}}})
+	}))
+	defer server.Close()
+
+	databases, err := newTestClient(server).SearchDatabases("")
+	if err != nil {
+		t.Fatalf("SearchDatabases failed: %v", err)
+	}
+
+	if len(databases) != 2 || databases[0].ID != "db-1" || databases[1].ID != "db-2" {
+		t.Errorf("Expected the live databases of both pages, got %+v", databases)
+	}
+
+	filter, _ := requests[0]["filter"].(map[string]interface{})
+	if filter["value"] != "database" {
+		t.Errorf("Expected search to be filtered to databases, got %v", requests[0]["filter"])
+	}
+}
+
+func TestCreateDatabase(t *testing.T) {
+	var body map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/databases" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, Database{ID: "db-new"})
+	}))
+	defer server.Close()
+
+	schema := JournalSchema(config.PropertyMap{"title": "Judul", "word_count": "none"})
+	database, err := newTestClient(server).CreateDatabase("page-1", "Journal", schema)
+	if err != nil {
+		t.Fatalf("CreateDatabase failed: %v", err)
+	}
+	if database.ID != "db-new" {
+		t.Errorf("Expected db-new, got %s", database.ID)
+	}
+
+	parent, _ := body["parent"].(map[string]interface{})
+	if parent["page_id"] != "page-1" {
+		t.Errorf("Expected parent page-1, got %v", body["parent"])
+	}
+
+	properties, _ := body["properties"].(map[string]interface{})
+	for _, name := range []string{"Judul", "Date", "HFL_Date", "Sync Status"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("Expected property %q, got %v", name, properties)
+		}
+	}
+	if len(properties) != 4 {
+		t.Errorf("Expected disabled fields to be left out, got %v", properties)
+	}
+}
+
+func TestJournalSchema_DefaultTitle(t *testing.T) {
+	schema := JournalSchema(nil)
+
+	title, ok := schema["Name"].(map[string]interface{})
+	if !ok || title["title"] == nil {
+		t.Errorf("Expected a Name title property, got %v", schema)
+	}
+}
+
+func TestGetMe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, APIError{Code: "unauthorized", Message: "API token is invalid."})
+			return
+		}
+		writeJSON(w, User{ID: "bot-1", Name: "HFL", Type: "bot", Bot: &Bot{WorkspaceName: "Home"}})
+	}))
+	defer server.Close()
+
+	user, err := newTestClient(server).GetMe()
+	if err != nil {
+		t.Fatalf("GetMe failed: %v", err)
+	}
+	if name := user.DisplayName(); name != "HFL in Home" {
+		t.Errorf("Expected 'HFL in Home', got %q", name)
+	}
+
+	client := newTestClient(server)
+	client.token = "wrong"
+	if _, err := client.GetMe(); !IsUnauthorized(err) {
+		t.Errorf("Expected an unauthorized error, got %v", err)
+	}
+}
+
+func TestVerifyDatabase_ExplainsNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, APIError{Code: "object_not_found", Message: "Could not find database."})
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server).VerifyDatabase("db-1")
+	if !IsNotFound(err) {
+		t.Fatalf("Expected a not found error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "share the database with your integration") {
+		t.Errorf("Expected the error to explain sharing, got %q", err)
+	}
+}
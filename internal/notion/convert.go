@@ -0,0 +1,262 @@
+package notion
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/ahmaruff/hfl/internal/markdown"
+)
+
+// MarkdownToBlocks converts an entry body to Notion blocks
+func MarkdownToBlocks(body string) []Block {
+	return toBlocks(markdown.Parse(body))
+}
+
+// BlocksToMarkdown renders Notion blocks as an entry body. It is the
+// inverse of MarkdownToBlocks; block types with no Markdown form are
+// skipped.
+func BlocksToMarkdown(blocks []Block) string {
+	return markdown.Render(fromBlocks(blocks))
+}
+
+func toBlocks(nodes []markdown.Block) []Block {
+	blocks := make([]Block, 0, len(nodes))
+
+	for _, node := range nodes {
+		text := richText(node.Text)
+
+		var block Block
+		switch node.Kind {
+		case markdown.Heading:
+			block = newHeading(text, node.Level)
+		case markdown.BulletItem:
+			block = Block{Type: "bulleted_list_item", BulletedListItem: &ListItemBlock{RichText: text}}
+		case markdown.NumberedItem:
+			block = Block{Type: "numbered_list_item", NumberedListItem: &ListItemBlock{RichText: text}}
+		case markdown.ToDo:
+			block = Block{Type: "to_do", ToDo: &ToDoBlock{RichText: text, Checked: node.Checked}}
+		case markdown.Quote:
+			block = Block{Type: "quote", Quote: &QuoteBlock{RichText: text}}
+		case markdown.Callout:
+			block = Block{Type: "callout", Callout: &CalloutBlock{
+				RichText: text,
+				Icon:     &Icon{Type: "emoji", Emoji: calloutEmoji[node.Callout]},
+			}}
+		case markdown.Code:
+			block = Block{Type: "code", Code: &CodeBlock{RichText: text, Language: notionLanguage(node.Language)}}
+		case markdown.Divider:
+			block = Block{Type: "divider", Divider: &DividerBlock{}}
+		default:
+			block = Block{Type: "paragraph", Paragraph: &ParagraphBlock{RichText: text}}
+		}
+
+		if len(node.Children) > 0 {
+			block.setChildren(toBlocks(node.Children))
+		}
+
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+func fromBlocks(blocks []Block) []markdown.Block {
+	var nodes []markdown.Block
+
+	for _, block := range blocks {
+		node, ok := fromBlock(block)
+		if !ok {
+			fmt.Printf("Unsupported block type: %s (skipping)\n", block.Type)
+			continue
+		}
+
+		node.Children = fromBlocks(block.Children())
+		nodes = append(nodes, node)
+	}
+
+	return nodes
+}
+
+// fromBlock converts a single block, without its children. It reports
+// false for block types with no Markdown form.
+func fromBlock(block Block) (markdown.Block, bool) {
+	node := markdown.Block{Text: spans(block.RichText())}
+
+	switch block.Type {
+	case "paragraph":
+		node.Kind = markdown.Paragraph
+	case "heading_1", "heading_2", "heading_3":
+		node.Kind = markdown.Heading
+		node.Level = int(block.Type[len("heading_")] - '0')
+	case "bulleted_list_item":
+		node.Kind = markdown.BulletItem
+	case "numbered_list_item":
+		node.Kind = markdown.NumberedItem
+	case "to_do":
+		node.Kind = markdown.ToDo
+		node.Checked = block.ToDo.Checked
+	case "quote":
+		node.Kind = markdown.Quote
+	case "callout":
+		node.Kind = markdown.Callout
+		node.Callout = calloutType(block.Callout.Icon)
+	case "code":
+		node.Kind = markdown.Code
+		node.Language = markdownLanguage(block.Code.Language)
+		// Code is kept as typed, without styles
+		if code := markdown.PlainText(node.Text); code != "" {
+			node.Text = []markdown.Span{{Text: code}}
+		}
+	case "divider":
+		node.Kind = markdown.Divider
+	case "toggle":
+		// No Markdown form; keep its text as a paragraph
+		node.Kind = markdown.Paragraph
+	default:
+		return markdown.Block{}, false
+	}
+
+	return node, true
+}
+
+// calloutEmoji is the icon given to each callout type. Callouts made in
+// Notion with any other icon come back as notes.
+var calloutEmoji = map[string]string{
+	"NOTE":      "ℹ️",
+	"TIP":       "💡",
+	"IMPORTANT": "❗",
+	"WARNING":   "⚠️",
+	"CAUTION":   "🛑",
+}
+
+func calloutType(icon *Icon) string {
+	if icon != nil {
+		for kind, emoji := range calloutEmoji {
+			if icon.Emoji == emoji {
+				return kind
+			}
+		}
+	}
+	return "NOTE"
+}
+
+// notionLanguages are the code block languages Notion accepts
+var notionLanguages = strings.Fields(`abap arduino bash basic c clojure coffeescript c++ c# css dart diff
+	docker elixir elm erlang flow fortran f# gherkin glsl go graphql groovy haskell html java
+	javascript json julia kotlin latex less lisp livescript lua makefile markdown markup matlab
+	mermaid nix objective-c ocaml pascal perl php powershell prolog protobuf python r reason
+	ruby rust sass scala scheme scss shell solidity sql swift toml typescript verilog vhdl xml
+	yaml`)
+
+// notionLanguage maps a fence language to Notion's. Languages Notion
+// doesn't know, and fences without one, become plain text.
+func notionLanguage(language string) string {
+	language = strings.ToLower(language)
+	for _, known := range notionLanguages {
+		if language == known {
+			return known
+		}
+	}
+	return "plain text"
+}
+
+func markdownLanguage(language string) string {
+	if language == "plain text" {
+		return ""
+	}
+	return language
+}
+
+func newHeading(text []TextObject, level int) Block {
+	switch level {
+	case 1:
+		return Block{Type: "heading_1", Heading1: &HeadingBlock{RichText: text}}
+	case 2:
+		return Block{Type: "heading_2", Heading2: &HeadingBlock{RichText: text}}
+	default:
+		return Block{Type: "heading_3", Heading3: &HeadingBlock{RichText: text}}
+	}
+}
+
+// richText converts spans to Notion rich text. The result is never nil,
+// since Notion rejects a null rich_text.
+func richText(spans []markdown.Span) []TextObject {
+	objects := make([]TextObject, 0, len(spans))
+
+	for _, span := range spans {
+		// Long text is split over several objects with the same style
+		for _, chunk := range splitText(span.Text, maxTextLength) {
+			text := &Text{Content: chunk}
+			if span.Link != "" {
+				text.Link = &Link{URL: span.Link}
+			}
+
+			objects = append(objects, TextObject{
+				Type: "text",
+				Text: text,
+				Annotations: &Annotations{
+					Bold:          span.Bold,
+					Italic:        span.Italic,
+					Strikethrough: span.Strike,
+					Underline:     span.Underline,
+					Code:          span.Code,
+					Color:         "default",
+				},
+			})
+		}
+	}
+
+	return objects
+}
+
+// maxTextLength is the most characters Notion accepts in the content of
+// one rich text object. Notion counts UTF-16 code units.
+const maxTextLength = 2000
+
+// splitText cuts text into pieces of at most limit UTF-16 code units,
+// never inside a character
+func splitText(text string, limit int) []string {
+	var pieces []string
+
+	start, length := 0, 0
+	for i, r := range text {
+		size := utf16.RuneLen(r)
+		if size < 0 {
+			size = 1
+		}
+		if length+size > limit {
+			pieces = append(pieces, text[start:i])
+			start, length = i, 0
+		}
+		length += size
+	}
+
+	return append(pieces, text[start:])
+}
+
+// spans converts Notion rich text back to spans, keeping annotations.
+// Objects other than text, such as mentions, keep their plain text.
+func spans(objects []TextObject) []markdown.Span {
+	var result []markdown.Span
+
+	for _, object := range objects {
+		span := markdown.Span{Text: object.PlainText}
+		if object.Text != nil {
+			span.Text = object.Text.Content
+			if object.Text.Link != nil {
+				span.Link = object.Text.Link.URL
+			}
+		}
+		if a := object.Annotations; a != nil {
+			span.Bold = a.Bold
+			span.Italic = a.Italic
+			span.Strike = a.Strikethrough
+			span.Underline = a.Underline
+			span.Code = a.Code
+		}
+		result = append(result, span)
+	}
+
+	return result
+}
@@ -0,0 +1,141 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ahmaruff/hfl/internal/markdown"
+	"github.com/ahmaruff/hfl/internal/parser"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+// Bodies covering every construct the converter supports
+var convertBodies = []string{
+	"Just one line.",
+	"First paragraph.\n\nSecond paragraph.",
+	"Line one\nline two.",
+	"# Title\n\n## Section\n\n### Subsection\n\nText.",
+	"Things to buy:\n\n- milk\n- eggs\n\nThat's all.",
+	"1. first\n2. second",
+	"- parent\n  - child\n    1. grandchild\n    2. grandchild\n  - [ ] task\n- sibling",
+	"Read [the docs](https://example.com/docs) today.",
+	"Notes [draft] stay as they are.",
+	"\\# not a heading\n\n\\- not a bullet",
+	"Some **bold**, *italic*, ~~struck~~, <u>underlined</u> and `code`.",
+	"**bold with *italic* inside** and [a **styled** link](https://example.com)",
+	"```go\nfunc main() {\n\n\tprintln(\"**hi**\")\n}\n```\n\n```\nno language\n```",
+	"> Quoted\n> twice.\n\n> [!WARNING]\n> Careful.",
+	"- [ ] write\n- [x] read\n\n---\n\nDone.",
+}
+
+func TestConvert_RoundTrip(t *testing.T) {
+	for _, body := range convertBodies {
+		// Through JSON, as the blocks travel to Notion and back
+		data, err := json.Marshal(MarkdownToBlocks(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var blocks []Block
+		if err := json.Unmarshal(data, &blocks); err != nil {
+			t.Fatal(err)
+		}
+
+		if content := BlocksToMarkdown(blocks); content != body {
+			t.Errorf("Expected %q to round-trip, got %q", body, content)
+		}
+	}
+}
+
+func TestConvert_PushThenPull(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{}
+	for i, body := range convertBodies {
+		date := fmt.Sprintf("2025-08-%02d", i+1)
+		journal.Entries = append(journal.Entries, parser.Entry{Date: date, Body: body})
+	}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	for _, entry := range journal.Entries {
+		content, err := service.PageContent(syncState.Entries[entry.Date].RemoteID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content != entry.Body {
+			t.Errorf("Expected %s to come back as %q, got %q", entry.Date, entry.Body, content)
+		}
+	}
+
+	// Nothing reads as a remote change right after the push
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if report := engine.Report(); report[len(report)-1].Action == hflsync.ActionPulled {
+		t.Errorf("Expected nothing to be pulled, got %+v", report[len(report)-1])
+	}
+}
+
+func TestMarkdownToBlocks_Annotations(t *testing.T) {
+	blocks := MarkdownToBlocks("~~**done**~~ `x`")
+	text := blocks[0].RichText()
+
+	if len(text) != 3 {
+		t.Fatalf("Expected 3 rich text objects, got %d", len(text))
+	}
+
+	done := text[0].Annotations
+	if !done.Bold || !done.Strikethrough || done.Italic || done.Code {
+		t.Errorf("Expected bold strikethrough, got %+v", done)
+	}
+	if !text[2].Annotations.Code || text[2].Text.Content != "x" {
+		t.Errorf("Expected code x, got %+v", text[2])
+	}
+}
+
+func TestMarkdownToBlocks_UnknownLanguage(t *testing.T) {
+	block := MarkdownToBlocks("```brainfuck\n+.\n```")[0]
+
+	if block.Code.Language != "plain text" {
+		t.Errorf("Expected plain text, got %q", block.Code.Language)
+	}
+	if content := BlocksToMarkdown([]Block{block}); content != "```\n+.\n```" {
+		t.Errorf("Expected the fence without a language, got %q", content)
+	}
+}
+
+func TestBlocksToMarkdown_CalloutIcon(t *testing.T) {
+	blocks := []Block{{
+		Type: "callout",
+		Callout: &CalloutBlock{
+			RichText: richText(markdown.ParseInline("Made in Notion")),
+			Icon:     &Icon{Type: "emoji", Emoji: "🐱"},
+		},
+	}}
+
+	if content := BlocksToMarkdown(blocks); content != "> [!NOTE]\n> Made in Notion" {
+		t.Errorf("Expected an unknown icon to come back as a note, got %q", content)
+	}
+}
+
+func TestBlocksToMarkdown_MentionsKeepPlainText(t *testing.T) {
+	blocks := []Block{{
+		Type: "paragraph",
+		Paragraph: &ParagraphBlock{RichText: []TextObject{
+			{Type: "text", Text: &Text{Content: "Met "}},
+			{Type: "mention", PlainText: "@Sam"},
+		}},
+	}}
+
+	if content := BlocksToMarkdown(blocks); content != "Met @Sam" {
+		t.Errorf("Expected mention text to be kept, got %q", content)
+	}
+}
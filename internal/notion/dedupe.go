@@ -0,0 +1,148 @@
+package notion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ahmaruff/hfl/internal/state"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+// Duplicate is a date with more than one live page in the database
+type Duplicate struct {
+	Date  string
+	Pages []Page // most recently edited first
+}
+
+// FindDuplicates reads the database and returns every date that has more
+// than one page, oldest date first
+func (s *SyncService) FindDuplicates() ([]Duplicate, error) {
+	s.pagesByDate = nil
+	pagesByDate, err := s.indexPages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+
+	var duplicates []Duplicate
+	for date, pages := range pagesByDate {
+		if len(pages) < 2 {
+			continue
+		}
+
+		sorted := append([]Page(nil), pages...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].LastEditedTime.After(sorted[j].LastEditedTime)
+		})
+		duplicates = append(duplicates, Duplicate{Date: date, Pages: sorted})
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].Date < duplicates[j].Date
+	})
+
+	return duplicates, nil
+}
+
+// KeepNewest archives every page of a duplicate except the most recently
+// edited one, and points state at the survivor
+func (s *SyncService) KeepNewest(syncState *state.State, duplicate Duplicate) error {
+	survivor := duplicate.Pages[0]
+
+	if err := s.archiveDuplicates(duplicate); err != nil {
+		return err
+	}
+
+	s.linkSurvivor(syncState, duplicate.Date, survivor.ID)
+	return syncState.Save()
+}
+
+// MergeDuplicate writes the combined body of every page of a duplicate into
+// the most recently edited one, then archives the rest and points state at
+// the survivor. It returns the merged body.
+func (s *SyncService) MergeDuplicate(syncState *state.State, duplicate Duplicate) (string, error) {
+	// Combine in creation order, so the merged body reads chronologically
+	pages := append([]Page(nil), duplicate.Pages...)
+	sort.SliceStable(pages, func(i, j int) bool {
+		return pages[i].CreatedTime.Before(pages[j].CreatedTime)
+	})
+
+	var bodies []string
+	for _, page := range pages {
+		content, err := s.getPageContent(page.ID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get content of page %s: %w", page.ID, err)
+		}
+		bodies = append(bodies, content)
+	}
+
+	merged := mergeBodies(bodies)
+	survivor := duplicate.Pages[0]
+
+	if err := s.client.UpdateBlockChildren(survivor.ID, MarkdownToBlocks(merged)); err != nil {
+		return "", fmt.Errorf("failed to update page %s: %w", survivor.ID, err)
+	}
+
+	// Modified: the body changed in Notion and hasn't been pulled yet
+	if _, err := s.client.UpdatePage(survivor.ID, s.metadata(merged, "Modified")); err != nil {
+		return "", fmt.Errorf("failed to update page %s: %w", survivor.ID, err)
+	}
+
+	s.record(duplicate.Date, hflsync.ActionMerged, fmt.Sprintf("%d pages into page %s", len(pages), survivor.ID))
+
+	if err := s.archiveDuplicates(duplicate); err != nil {
+		return "", err
+	}
+
+	s.linkSurvivor(syncState, duplicate.Date, survivor.ID)
+	return merged, syncState.Save()
+}
+
+// archiveDuplicates archives every page of a duplicate but the first
+func (s *SyncService) archiveDuplicates(duplicate Duplicate) error {
+	survivor := duplicate.Pages[0]
+
+	for _, page := range duplicate.Pages[1:] {
+		if err := s.client.ArchivePage(page.ID); err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to archive page %s: %w", page.ID, err)
+		}
+		s.record(duplicate.Date, hflsync.ActionArchived, "duplicate of page "+survivor.ID)
+	}
+
+	return nil
+}
+
+// linkSurvivor points an entry at the page that survived deduplication.
+// The remote hash is dropped, so the next sync compares the page with the
+// local body in full and pulls or flags the difference.
+func (s *SyncService) linkSurvivor(syncState *state.State, date, pageID string) {
+	entryState, exists := syncState.GetEntry(date)
+	if !exists || entryState.Unlinked {
+		// Not synced yet, so the next sync adopts the survivor, or kept
+		// out of sync on purpose
+		return
+	}
+
+	syncState.SetRemoteID(date, pageID)
+	syncState.SetRemote(date, "", "")
+}
+
+// mergeBodies combines bodies paragraph by paragraph, keeping the first
+// occurrence of any paragraph found in more than one of them
+func mergeBodies(bodies []string) string {
+	var merged []string
+	seen := make(map[string]bool)
+
+	for _, body := range bodies {
+		for _, paragraph := range strings.Split(body, "\n\n") {
+			paragraph = strings.TrimSpace(paragraph)
+			if paragraph == "" || seen[paragraph] {
+				continue
+			}
+			seen[paragraph] = true
+			merged = append(merged, paragraph)
+		}
+	}
+
+	return strings.Join(merged, "\n\n")
+}
@@ -0,0 +1,132 @@
+package notion
+
+import (
+	"testing"
+
+	"github.com/ahmaruff/hfl/internal/parser"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+
+	older := fake.addPage("2025-08-16", "First copy.")
+	newer := fake.addPage("2025-08-16", "Second copy.")
+	fake.addPage("2025-08-15", "Unique.")
+
+	duplicates, err := service.FindDuplicates()
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+
+	if len(duplicates) != 1 || duplicates[0].Date != "2025-08-16" {
+		t.Fatalf("Expected one duplicate for 2025-08-16, got %+v", duplicates)
+	}
+
+	pages := duplicates[0].Pages
+	if len(pages) != 2 || pages[0].ID != newer || pages[1].ID != older {
+		t.Errorf("Expected pages newest first, got %v, %v", pages[0].ID, pages[1].ID)
+	}
+}
+
+func TestKeepNewest(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First copy."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	older := syncState.Entries["2025-08-16"].RemoteID
+	newer := fake.addPage("2025-08-16", "Second copy.")
+
+	duplicates, err := service.FindDuplicates()
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+
+	if err := service.KeepNewest(syncState, duplicates[0]); err != nil {
+		t.Fatalf("KeepNewest failed: %v", err)
+	}
+
+	if !fake.pages[older].Archived || fake.pages[newer].Archived {
+		t.Error("Expected only the older page to be archived")
+	}
+
+	if syncState.Entries["2025-08-16"].RemoteID != newer {
+		t.Error("Expected state to point at the newest page")
+	}
+
+	// The next pull brings in the survivor's body
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if journal.Entries[0].Body != "Second copy." {
+		t.Errorf("Expected survivor to be pulled, got %q", journal.Entries[0].Body)
+	}
+}
+
+func TestMergeDuplicate(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+
+	older := fake.addPage("2025-08-16", "Shared.\n\nOnly in the first.")
+	newer := fake.addPage("2025-08-16", "Shared.\n\nOnly in the second.")
+
+	duplicates, err := service.FindDuplicates()
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+
+	merged, err := service.MergeDuplicate(newState(), duplicates[0])
+	if err != nil {
+		t.Fatalf("MergeDuplicate failed: %v", err)
+	}
+
+	expected := "Shared.\n\nOnly in the first.\n\nOnly in the second."
+	if merged != expected {
+		t.Errorf("Expected merged body %q, got %q", expected, merged)
+	}
+
+	if content, _ := service.PageContent(newer); content != expected {
+		t.Errorf("Expected survivor to hold the merged body, got %q", content)
+	}
+	if !fake.pages[older].Archived {
+		t.Error("Expected the other page to be archived")
+	}
+
+	if duplicates, _ := service.FindDuplicates(); len(duplicates) != 0 {
+		t.Errorf("Expected no duplicates left, got %+v", duplicates)
+	}
+}
+
+func TestPull_SkipsDuplicates(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	fake.addPage("2025-08-16", "First copy.")
+	fake.addPage("2025-08-16", "Second copy.")
+
+	journal := &parser.Journal{}
+	if err := engine.Pull(journal, newState()); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	if len(journal.Entries) != 0 {
+		t.Errorf("Expected duplicated date not to be pulled, got %+v", journal.Entries)
+	}
+
+	report := engine.Report()
+	if len(report) != 1 || report[0].Action != hflsync.ActionSkipped {
+		t.Errorf("Expected the duplicate to be reported as skipped, got %+v", report)
+	}
+}
@@ -0,0 +1,125 @@
+package notion
+
+import (
+	"github.com/ahmaruff/hfl/internal/markdown"
+)
+
+// blockStep is what happens to one block of the desired content
+type blockStep struct {
+	block    Block  // the desired block
+	existing *Block // the remote block kept or patched, nil to insert
+	patch    bool   // whether the remote block's content changes
+}
+
+// diffBlocks lines the existing children of a block up with the desired
+// ones. Unchanged blocks are kept, changed blocks of the same type are
+// patched in place, and everything else is inserted or deleted. Steps
+// come in the desired order.
+func diffBlocks(existing, desired []Block) ([]blockStep, []Block) {
+	existingKeys := make([]string, len(existing))
+	for i, block := range existing {
+		existingKeys[i] = blockKey(block)
+	}
+	desiredKeys := make([]string, len(desired))
+	for j, block := range desired {
+		desiredKeys[j] = blockKey(block)
+	}
+
+	// common[i][j] is the longest common run of existing[i:] and desired[j:]
+	common := make([][]int, len(existing)+1)
+	for i := range common {
+		common[i] = make([]int, len(desired)+1)
+	}
+	for i := len(existing) - 1; i >= 0; i-- {
+		for j := len(desired) - 1; j >= 0; j-- {
+			if existingKeys[i] != "" && existingKeys[i] == desiredKeys[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var steps []blockStep
+	var deletes []Block
+	var gapExisting, gapDesired []int
+
+	// Blocks between two unchanged ones are patched when a remote block of
+	// the same type is left to reuse
+	closeGap := func() {
+		next := 0
+		for _, j := range gapDesired {
+			match := -1
+			for k := next; k < len(gapExisting); k++ {
+				if existing[gapExisting[k]].Type == desired[j].Type && existingKeys[gapExisting[k]] != "" {
+					match = k
+					break
+				}
+			}
+			if match < 0 {
+				steps = append(steps, blockStep{block: desired[j]})
+				continue
+			}
+
+			for _, i := range gapExisting[next:match] {
+				deletes = append(deletes, existing[i])
+			}
+			steps = append(steps, blockStep{block: desired[j], existing: &existing[gapExisting[match]], patch: true})
+			next = match + 1
+		}
+
+		for _, i := range gapExisting[next:] {
+			deletes = append(deletes, existing[i])
+		}
+		gapExisting, gapDesired = nil, nil
+	}
+
+	i, j := 0, 0
+	for i < len(existing) || j < len(desired) {
+		switch {
+		case i < len(existing) && j < len(desired) && existingKeys[i] != "" && existingKeys[i] == desiredKeys[j]:
+			closeGap()
+			steps = append(steps, blockStep{block: desired[j], existing: &existing[i]})
+			i++
+			j++
+		case j >= len(desired) || (i < len(existing) && common[i+1][j] >= common[i][j+1]):
+			gapExisting = append(gapExisting, i)
+			i++
+		default:
+			gapDesired = append(gapDesired, j)
+			j++
+		}
+	}
+	closeGap()
+
+	// Notion can only insert after a block, so new blocks at the top can't
+	// go before blocks that stay. Rewrite the whole list instead.
+	if len(steps) > 0 && steps[0].existing == nil {
+		for _, step := range steps {
+			if step.existing != nil {
+				return rewriteSteps(desired), existing
+			}
+		}
+	}
+
+	return steps, deletes
+}
+
+func rewriteSteps(desired []Block) []blockStep {
+	steps := make([]blockStep, len(desired))
+	for i, block := range desired {
+		steps[i] = blockStep{block: block}
+	}
+	return steps
+}
+
+// blockKey identifies the content of a block, without its ID or children.
+// Blocks with the same key read the same in Markdown. Block types with no
+// Markdown form get an empty key and never match.
+func blockKey(block Block) string {
+	node, ok := fromBlock(block)
+	if !ok {
+		return ""
+	}
+	return block.Type + "\n" + markdown.Render([]markdown.Block{node})
+}
@@ -0,0 +1,167 @@
+package notion
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// pushBody creates a page holding body and clears the recorded requests
+func pushBody(t *testing.T, fake *fakeNotion, client *Client, body string) string {
+	t.Helper()
+
+	page, err := client.CreatePage("db-1", Properties{}, MarkdownToBlocks(body))
+	if err != nil {
+		t.Fatalf("CreatePage failed: %v", err)
+	}
+
+	fake.mu.Lock()
+	fake.requests, fake.batches = nil, nil
+	fake.mu.Unlock()
+	return page.ID
+}
+
+// pageBody reads a page back as Markdown
+func pageBody(t *testing.T, client *Client, pageID string) string {
+	t.Helper()
+
+	blocks, err := client.GetBlockTree(pageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return BlocksToMarkdown(blocks)
+}
+
+func TestUpdateBlockChildren_PatchesOnlyChangedBlock(t *testing.T) {
+	fake := newFakeNotion(t)
+	client := newTestClient(fake.server)
+
+	var paragraphs []string
+	for i := 0; i < 20; i++ {
+		paragraphs = append(paragraphs, fmt.Sprintf("Paragraph %d.", i))
+	}
+	pageID := pushBody(t, fake, client, strings.Join(paragraphs, "\n\n"))
+	before := fake.children[pageID]
+
+	paragraphs[7] = "Paragraph seven, fixed."
+	body := strings.Join(paragraphs, "\n\n")
+	if err := client.UpdateBlockChildren(pageID, MarkdownToBlocks(body)); err != nil {
+		t.Fatalf("UpdateBlockChildren failed: %v", err)
+	}
+
+	expected := []string{"GET /blocks/" + pageID + "/children", "PATCH /blocks/" + before[7].ID}
+	if strings.Join(fake.requests, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected requests %v, got %v", expected, fake.requests)
+	}
+
+	for i, block := range fake.children[pageID] {
+		if block.ID != before[i].ID {
+			t.Errorf("Expected block %d to keep ID %s, got %s", i, before[i].ID, block.ID)
+		}
+	}
+
+	if content := pageBody(t, client, pageID); content != body {
+		t.Errorf("Expected %q, got %q", body, content)
+	}
+}
+
+func TestUpdateBlockChildren_InsertsAndDeletes(t *testing.T) {
+	fake := newFakeNotion(t)
+	client := newTestClient(fake.server)
+
+	pageID := pushBody(t, fake, client, "One.\n\nTwo.\n\nThree.\n\nFour.")
+
+	body := "One.\n\n# New\n\n- a\n- b\n\nThree.\n\nFour."
+	if err := client.UpdateBlockChildren(pageID, MarkdownToBlocks(body)); err != nil {
+		t.Fatalf("UpdateBlockChildren failed: %v", err)
+	}
+
+	// "Two." can't become a heading, so it goes and the new blocks are
+	// inserted in one request after "One."
+	if n := fake.count("PATCH", "/blocks/"+pageID+"/children"); n != 1 {
+		t.Errorf("Expected 1 insert, got %d: %v", n, fake.requests)
+	}
+	if n := fake.count("DELETE", "/blocks/"); n != 1 {
+		t.Errorf("Expected 1 delete, got %d: %v", n, fake.requests)
+	}
+
+	if content := pageBody(t, client, pageID); content != body {
+		t.Errorf("Expected %q, got %q", body, content)
+	}
+}
+
+func TestUpdateBlockChildren_NestedChange(t *testing.T) {
+	fake := newFakeNotion(t)
+	client := newTestClient(fake.server)
+
+	pageID := pushBody(t, fake, client, "- parent\n  - child\n  - other\n- sibling")
+
+	body := "- parent\n  - child, edited\n  - other\n- sibling"
+	if err := client.UpdateBlockChildren(pageID, MarkdownToBlocks(body)); err != nil {
+		t.Fatalf("UpdateBlockChildren failed: %v", err)
+	}
+
+	if n := fake.count("PATCH", "/blocks/"); n != 1 {
+		t.Errorf("Expected only the child to be patched, got %v", fake.requests)
+	}
+	if content := pageBody(t, client, pageID); content != body {
+		t.Errorf("Expected %q, got %q", body, content)
+	}
+}
+
+func TestUpdateBlockChildren_NewFirstBlock(t *testing.T) {
+	fake := newFakeNotion(t)
+	client := newTestClient(fake.server)
+
+	pageID := pushBody(t, fake, client, "Kept.\n\nAlso kept.")
+
+	// Nothing can anchor a heading before "Kept.", so the page is rewritten
+	body := "# Title\n\nKept.\n\nAlso kept."
+	if err := client.UpdateBlockChildren(pageID, MarkdownToBlocks(body)); err != nil {
+		t.Fatalf("UpdateBlockChildren failed: %v", err)
+	}
+
+	if content := pageBody(t, client, pageID); content != body {
+		t.Errorf("Expected %q, got %q", body, content)
+	}
+}
+
+func TestUpdateBlockChildren_Edits(t *testing.T) {
+	edits := []struct{ from, to string }{
+		{"", "Fresh."},
+		{"Gone.", ""},
+		{"A.\n\nB.\n\nC.", "C.\n\nB.\n\nA."},
+		{"A.\n\nB.", "New.\n\nA.\n\nB."},
+		{"- [ ] task\n- [ ] other", "- [x] task\n- [ ] other"},
+		{"```go\nx\n```", "```go\ny\n```\n\nAfter."},
+		{"1. a\n2. b\n3. c", "1. a\n2. c"},
+		{"- a\n  - b", "- a\n- b"},
+	}
+
+	for _, edit := range edits {
+		fake := newFakeNotion(t)
+		client := newTestClient(fake.server)
+
+		pageID := pushBody(t, fake, client, edit.from)
+		if err := client.UpdateBlockChildren(pageID, MarkdownToBlocks(edit.to)); err != nil {
+			t.Fatalf("UpdateBlockChildren(%q -> %q) failed: %v", edit.from, edit.to, err)
+		}
+
+		if content := pageBody(t, client, pageID); content != edit.to {
+			t.Errorf("Expected %q -> %q, got %q", edit.from, edit.to, content)
+		}
+	}
+}
+
+func TestUpdateBlockChildren_FailedDeleteStops(t *testing.T) {
+	fake := newFakeNotion(t)
+	client := newTestClient(fake.server)
+
+	pageID := pushBody(t, fake, client, "One.\n\n# Two\n\nThree.")
+	fake.deleteError = true
+
+	err := client.UpdateBlockChildren(pageID, MarkdownToBlocks("One.\n\nThree."))
+	if err == nil || !strings.Contains(err.Error(), "failed to delete block") {
+		t.Fatalf("Expected the failed delete to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,363 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+// fakeNotion is an in-memory stand-in for the parts of the Notion API the
+// sync service uses. Every write advances its clock by a minute, matching
+// the minute granularity of Notion's last_edited_time.
+type fakeNotion struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu       sync.Mutex
+	now      time.Time
+	nextID   int
+	order    []string
+	pages    map[string]*Page
+	children map[string][]Block
+	requests []string
+	batches  []int // blocks sent with each request, 0 when none
+
+	// createLimit makes page creation fail once that many pages exist
+	createLimit int
+	// deleteError makes every block delete fail
+	deleteError bool
+}
+
+func newFakeNotion(t *testing.T) *fakeNotion {
+	f := &fakeNotion{
+		t:        t,
+		now:      time.Date(2025, 8, 16, 10, 0, 0, 0, time.UTC),
+		pages:    make(map[string]*Page),
+		children: make(map[string][]Block),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// service returns a sync service wired to the fake
+func (f *fakeNotion) service() *SyncService {
+	return &SyncService{
+		client:     newTestClient(f.server),
+		databaseID: "db-1",
+	}
+}
+
+// addPage creates a page directly, as if it was written in Notion
+func (f *fakeNotion) addPage(date, body string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	page := &Page{
+		ID: f.newID("page"),
+		Properties: Properties{
+			"Date":     NewDateProperty(date),
+			"HFL_Date": NewPlainRichTextProperty(date),
+		},
+	}
+	f.storePage(page, MarkdownToBlocks(body))
+	return page.ID
+}
+
+// editBody replaces a page's content, as if it was edited in Notion
+func (f *fakeNotion) editBody(pageID, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.children[pageID] = f.storeBlocks(MarkdownToBlocks(body))
+	f.touch(pageID)
+}
+
+// count returns how many requests matched the method and path prefix
+func (f *fakeNotion) count(method, pathPrefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, request := range f.requests {
+		if strings.HasPrefix(request, method+" "+pathPrefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeNotion) newID(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s-%d", prefix, f.nextID)
+}
+
+func (f *fakeNotion) touch(pageID string) {
+	f.now = f.now.Add(time.Minute)
+	f.pages[pageID].LastEditedTime = f.now
+}
+
+func (f *fakeNotion) storePage(page *Page, blocks []Block) {
+	f.now = f.now.Add(time.Minute)
+	page.CreatedTime = f.now
+	page.LastEditedTime = f.now
+	f.pages[page.ID] = page
+	f.order = append(f.order, page.ID)
+	f.children[page.ID] = f.storeBlocks(blocks)
+}
+
+// storeBlocks assigns IDs to blocks and moves their children into the
+// children map, the way Notion returns them
+func (f *fakeNotion) storeBlocks(blocks []Block) []Block {
+	stored := make([]Block, len(blocks))
+	for i, block := range blocks {
+		data, _ := json.Marshal(block)
+		var copied Block
+		json.Unmarshal(data, &copied)
+
+		copied.ID = f.newID("block")
+		if nested := copied.Children(); len(nested) > 0 {
+			f.children[copied.ID] = f.storeBlocks(nested)
+			copied.HasChildren = true
+			copied.setChildren(nil)
+		}
+		stored[i] = copied
+	}
+	return stored
+}
+
+func (f *fakeNotion) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+	f.batches = append(f.batches, 0)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	var body map[string]json.RawMessage
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&body)
+	}
+
+	switch {
+	case r.Method == "POST" && len(parts) == 3 && parts[0] == "databases" && parts[2] == "query":
+		// Only the edited-time filter is supported
+		var filter Filter
+		json.Unmarshal(body["filter"], &filter)
+		var since time.Time
+		if filter.LastEditedTime != nil {
+			since, _ = time.Parse(time.RFC3339, filter.LastEditedTime.OnOrAfter)
+		}
+
+		var results []Page
+		for _, id := range f.order {
+			page := f.pages[id]
+			if page.Archived || page.InTrash || page.LastEditedTime.Before(since) {
+				continue
+			}
+			results = append(results, *page)
+		}
+		writeJSON(w, QueryResponse{Results: results})
+
+	case r.Method == "POST" && len(parts) == 1 && parts[0] == "pages":
+		if f.createLimit > 0 && len(f.pages) >= f.createLimit {
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(w, APIError{StatusCode: 500, Code: "internal_server_error", Message: "create failed"})
+			return
+		}
+		var blocks []Block
+		json.Unmarshal(body["children"], &blocks)
+		if !f.withinLimits(w, blocks) {
+			return
+		}
+		page := &Page{ID: f.newID("page")}
+		json.Unmarshal(body["properties"], &page.Properties)
+		f.storePage(page, blocks)
+		writeJSON(w, page)
+
+	case r.Method == "GET" && len(parts) == 2 && parts[0] == "pages":
+		page, ok := f.pages[parts[1]]
+		if !ok {
+			f.notFound(w, r)
+			return
+		}
+		writeJSON(w, page)
+
+	case r.Method == "PATCH" && len(parts) == 2 && parts[0] == "pages":
+		page, ok := f.pages[parts[1]]
+		if !ok {
+			f.notFound(w, r)
+			return
+		}
+		if page.Archived || page.InTrash {
+			if _, unarchive := body["archived"]; !unarchive {
+				f.archivedError(w)
+				return
+			}
+		}
+		var properties Properties
+		json.Unmarshal(body["properties"], &properties)
+		for name, property := range properties {
+			page.Properties[name] = property
+		}
+		if archived, ok := body["archived"]; ok {
+			json.Unmarshal(archived, &page.Archived)
+		}
+		f.touch(page.ID)
+		writeJSON(w, page)
+
+	case r.Method == "GET" && len(parts) == 3 && parts[0] == "blocks" && parts[2] == "children":
+		writeJSON(w, BlockListResponse{Results: f.children[parts[1]]})
+
+	case r.Method == "PATCH" && len(parts) == 3 && parts[0] == "blocks" && parts[2] == "children":
+		if page, ok := f.pages[parts[1]]; ok && (page.Archived || page.InTrash) {
+			f.archivedError(w)
+			return
+		}
+		var blocks []Block
+		json.Unmarshal(body["children"], &blocks)
+		if !f.withinLimits(w, blocks) {
+			return
+		}
+		created := f.storeBlocks(blocks)
+		siblings := f.children[parts[1]]
+		at := len(siblings)
+		var after string
+		json.Unmarshal(body["after"], &after)
+		for i, block := range siblings {
+			if block.ID == after {
+				at = i + 1
+			}
+		}
+		f.children[parts[1]] = append(append(siblings[:at:at], created...), siblings[at:]...)
+		if _, ok := f.pages[parts[1]]; !ok {
+			f.markHasChildren(parts[1])
+		}
+		f.touchOwner(parts[1])
+		writeJSON(w, BlockListResponse{Results: created})
+
+	case r.Method == "PATCH" && len(parts) == 2 && parts[0] == "blocks":
+		for _, blocks := range f.children {
+			for i, block := range blocks {
+				if block.ID != parts[1] {
+					continue
+				}
+				// Replace the content, keeping the ID and children
+				data, _ := json.Marshal(map[string]json.RawMessage{"type": json.RawMessage(`"` + block.Type + `"`), block.Type: body[block.Type]})
+				var updated Block
+				json.Unmarshal(data, &updated)
+				updated.ID, updated.HasChildren = block.ID, block.HasChildren
+				blocks[i] = updated
+				f.touchOwner(block.ID)
+				writeJSON(w, updated)
+				return
+			}
+		}
+		f.notFound(w, r)
+
+	case r.Method == "DELETE" && len(parts) == 2 && parts[0] == "blocks":
+		if f.deleteError {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, APIError{StatusCode: 400, Code: "validation_error", Message: "delete failed"})
+			return
+		}
+		for parent, blocks := range f.children {
+			for i, block := range blocks {
+				if block.ID == parts[1] {
+					f.children[parent] = append(blocks[:i:i], blocks[i+1:]...)
+					f.touchOwner(parent)
+					writeJSON(w, block)
+					return
+				}
+			}
+		}
+		f.notFound(w, r)
+
+	default:
+		f.notFound(w, r)
+	}
+}
+
+// withinLimits rejects blocks the way Notion does when a request carries
+// more than 100 blocks in one array, nests them more than two levels
+// deep, or has rich text longer than 2000 characters. It records the
+// number of top-level blocks as the size of the request.
+func (f *fakeNotion) withinLimits(w http.ResponseWriter, blocks []Block) bool {
+	f.batches[len(f.batches)-1] = len(blocks)
+
+	var check func(blocks []Block, depth int) string
+	check = func(blocks []Block, depth int) string {
+		if len(blocks) > maxBlocksPerRequest {
+			return fmt.Sprintf("body.children.length should be ≤ 100, instead was %d", len(blocks))
+		}
+		if len(blocks) > 0 && depth > 2 {
+			return "body.children should be nested at most two levels deep"
+		}
+		for _, block := range blocks {
+			for _, text := range block.RichText() {
+				if text.Text != nil && len(utf16.Encode([]rune(text.Text.Content))) > maxTextLength {
+					return "body.children.rich_text.text.content.length should be ≤ 2000"
+				}
+			}
+			if message := check(block.Children(), depth+1); message != "" {
+				return message
+			}
+		}
+		return ""
+	}
+
+	if message := check(blocks, 1); message != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, APIError{StatusCode: 400, Code: "validation_error", Message: message})
+		return false
+	}
+	return true
+}
+
+// touchOwner touches the page a block belongs to, however deeply nested
+func (f *fakeNotion) touchOwner(blockID string) {
+	if _, ok := f.pages[blockID]; ok {
+		f.touch(blockID)
+		return
+	}
+	for parent, blocks := range f.children {
+		for _, block := range blocks {
+			if block.ID == blockID {
+				f.touchOwner(parent)
+				return
+			}
+		}
+	}
+}
+
+// markHasChildren flags a block once children are appended to it
+func (f *fakeNotion) markHasChildren(blockID string) {
+	for _, blocks := range f.children {
+		for i := range blocks {
+			if blocks[i].ID == blockID {
+				blocks[i].HasChildren = true
+			}
+		}
+	}
+}
+
+func (f *fakeNotion) archivedError(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusBadRequest)
+	writeJSON(w, APIError{StatusCode: 400, Code: "validation_error", Message: "Can't edit block that is archived."})
+}
+
+func (f *fakeNotion) notFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	writeJSON(w, APIError{StatusCode: 404, Code: "object_not_found", Message: r.Method + " " + r.URL.Path})
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,86 @@
+package notion
+
+import "time"
+
+// Query is the body of a database query: which pages to return and in
+// what order. The zero Query returns every page.
+type Query struct {
+	Filter *Filter `json:"filter,omitempty"`
+	Sorts  []Sort  `json:"sorts,omitempty"`
+}
+
+// Filter is a database query filter, on a property or on a page
+// timestamp. Build one with EditedSince, TextEquals, And and Or.
+type Filter struct {
+	Property  string `json:"property,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+
+	RichText       *TextCondition `json:"rich_text,omitempty"`
+	LastEditedTime *DateCondition `json:"last_edited_time,omitempty"`
+
+	And []Filter `json:"and,omitempty"`
+	Or  []Filter `json:"or,omitempty"`
+}
+
+type TextCondition struct {
+	Equals string `json:"equals,omitempty"`
+}
+
+type DateCondition struct {
+	OnOrAfter string `json:"on_or_after,omitempty"`
+	Before    string `json:"before,omitempty"`
+}
+
+// Sort orders query results by a property or a page timestamp
+type Sort struct {
+	Property  string `json:"property,omitempty"`
+	Timestamp string `json:"timestamp,omitempty"`
+	Direction string `json:"direction"`
+}
+
+// Sort directions
+const (
+	Ascending  = "ascending"
+	Descending = "descending"
+)
+
+// EditedSince matches pages last edited at or after t
+func EditedSince(t time.Time) *Filter {
+	return &Filter{
+		Timestamp:      "last_edited_time",
+		LastEditedTime: &DateCondition{OnOrAfter: t.UTC().Format(time.RFC3339)},
+	}
+}
+
+// TextEquals matches pages whose rich text property is exactly value
+func TextEquals(property, value string) *Filter {
+	return &Filter{Property: property, RichText: &TextCondition{Equals: value}}
+}
+
+// And matches pages that match every filter
+func And(filters ...*Filter) *Filter {
+	return &Filter{And: flatten(filters)}
+}
+
+// Or matches pages that match any filter
+func Or(filters ...*Filter) *Filter {
+	return &Filter{Or: flatten(filters)}
+}
+
+func flatten(filters []*Filter) []Filter {
+	result := make([]Filter, len(filters))
+	for i, filter := range filters {
+		result[i] = *filter
+	}
+	return result
+}
+
+// SortByEdited orders pages by their last-edited time
+func SortByEdited(direction string) Sort {
+	return Sort{Timestamp: "last_edited_time", Direction: direction}
+}
+
+// SortByProperty orders pages by a property
+func SortByProperty(property, direction string) Sort {
+	return Sort{Property: property, Direction: direction}
+}
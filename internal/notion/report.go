@@ -0,0 +1,15 @@
+package notion
+
+import (
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+// Report returns the decisions taken by dedupe so far, in the order they
+// were made
+func (s *SyncService) Report() []hflsync.ReportItem {
+	return s.report
+}
+
+func (s *SyncService) record(date, action, detail string) {
+	s.report = append(s.report, hflsync.ReportItem{Date: date, Action: action, Detail: detail})
+}
@@ -0,0 +1,98 @@
+package notion
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults keep the client under Notion's documented average of three
+// requests per second
+const (
+	DefaultRequestsPerSecond = 3
+	DefaultMaxRetries        = 5
+	DefaultBaseDelay         = 500 * time.Millisecond
+	DefaultMaxDelay          = 30 * time.Second
+)
+
+// ClientOptions tunes rate limiting and retries. Zero values use defaults,
+// except for MaxRetries, where nil does: 0 turns retries off.
+type ClientOptions struct {
+	RequestsPerSecond float64
+	MaxRetries        *int
+	BaseDelay         time.Duration
+	MaxDelay          time.Duration
+}
+
+func (o ClientOptions) withDefaults() ClientOptions {
+	if o.RequestsPerSecond <= 0 {
+		o.RequestsPerSecond = DefaultRequestsPerSecond
+	}
+	if o.MaxRetries == nil || *o.MaxRetries < 0 {
+		retries := DefaultMaxRetries
+		o.MaxRetries = &retries
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = DefaultBaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = DefaultMaxDelay
+	}
+	return o
+}
+
+// rateLimiter spaces requests evenly so that every request made through
+// a client shares the same budget
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(requestsPerSecond float64) *rateLimiter {
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+	}
+}
+
+// Wait blocks until the next request slot is free
+func (l *rateLimiter) Wait() {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// backoff returns how long to wait before retry number attempt (starting
+// at 0): exponential growth from base, capped at max, with full jitter
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	delay := base << attempt
+	if delay <= 0 || delay > max {
+		delay = max
+	}
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,142 @@
+package notion
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ahmaruff/hfl/internal/config"
+)
+
+// propertyTypes are the Notion property types each field is written as
+var propertyTypes = map[string]string{
+	"title":       "title",
+	"date":        "date",
+	"hfl_date":    "rich_text",
+	"word_count":  "number",
+	"sync_status": "select",
+}
+
+// SchemaProblem is a mapped property the database is missing, or has with
+// another type
+type SchemaProblem struct {
+	Field    string
+	Property string
+	Expected string
+	Actual   string // "" when the property is missing
+}
+
+func (p SchemaProblem) String() string {
+	if p.Actual == "" {
+		return fmt.Sprintf("%s: property %q is missing (expected %s)", p.Field, p.Property, p.Expected)
+	}
+	return fmt.Sprintf("%s: property %q is %s, expected %s", p.Field, p.Property, p.Actual, p.Expected)
+}
+
+// CanAdd reports whether the problem can be fixed by adding the property.
+// A database has exactly one title property, so it's never added.
+func (p SchemaProblem) CanAdd() bool {
+	return p.Actual == "" && p.Field != "title"
+}
+
+// ValidateDatabase checks that every enabled field maps to a property of
+// the right type. The schema is never changed.
+func (s *SyncService) ValidateDatabase() ([]SchemaProblem, error) {
+	db, err := s.client.GetDatabase(s.databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch database: %w", describeDatabaseError(s.databaseID, err))
+	}
+
+	props, ok := db["properties"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected schema format")
+	}
+
+	var problems []SchemaProblem
+	for _, field := range config.PropertyFields {
+		name := s.property(field)
+		if name == "" {
+			continue
+		}
+
+		problem := SchemaProblem{Field: field, Property: name, Expected: propertyTypes[field]}
+		problem.Actual = schemaType(props, name)
+		if problem.Actual != problem.Expected {
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems, nil
+}
+
+// AddMissingProperties creates the properties of problems that CanAdd.
+// Properties with the wrong type are left alone.
+func (s *SyncService) AddMissingProperties(problems []SchemaProblem) error {
+	missing := make(map[string]interface{})
+	for _, problem := range problems {
+		if problem.CanAdd() {
+			missing[problem.Property] = propertySchema(problem.Field)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := s.client.UpdateDatabase(s.databaseID, missing); err != nil {
+		return fmt.Errorf("failed to update database schema: %w", err)
+	}
+	return nil
+}
+
+// schemaType returns the type of the property with name or ID key, or ""
+func schemaType(props map[string]interface{}, key string) string {
+	for name, value := range props {
+		prop, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name == key || prop["id"] == key {
+			propType, _ := prop["type"].(string)
+			return propType
+		}
+	}
+	return ""
+}
+
+// propertySchema returns the definition of a new property for field
+func propertySchema(field string) map[string]interface{} {
+	if field != "sync_status" {
+		return map[string]interface{}{propertyTypes[field]: map[string]interface{}{}}
+	}
+
+	return map[string]interface{}{
+		"select": map[string]interface{}{
+			"options": []map[string]interface{}{
+				{"name": "Pending", "color": "blue"},
+				{"name": "Conflict", "color": "red"},
+				{"name": "Modified", "color": "yellow"},
+				{"name": "Synced", "color": "green"},
+			},
+		},
+	}
+}
+
+// property returns the property a field is written to, or "" when it's
+// disabled
+func (s *SyncService) property(field string) string {
+	return s.properties.Name(field)
+}
+
+// setProperty adds a field's value to properties, unless it's disabled
+func (s *SyncService) setProperty(properties Properties, field string, value Property) {
+	if name := s.property(field); name != "" {
+		properties[name] = value
+	}
+}
+
+// metadata returns the word count and sync status properties of a page
+func (s *SyncService) metadata(body, status string) Properties {
+	properties := Properties{}
+	s.setProperty(properties, "word_count", NewNumberProperty(float64(len(strings.Fields(body)))))
+	s.setProperty(properties, "sync_status", NewSelectProperty(status))
+	return properties
+}
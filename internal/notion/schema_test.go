@@ -0,0 +1,141 @@
+package notion
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ahmaruff/hfl/internal/config"
+	"github.com/ahmaruff/hfl/internal/parser"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+// fakeSchemaServer serves a database with the given property types and
+// records the properties sent to update it
+func fakeSchemaServer(t *testing.T, types map[string]string) (*httptest.Server, *map[string]interface{}) {
+	updated := make(map[string]interface{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/databases/db-1" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.Method == "PATCH" {
+			var body struct {
+				Properties map[string]interface{} `json:"properties"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for name, schema := range body.Properties {
+				updated[name] = schema
+			}
+		}
+
+		properties := make(map[string]interface{})
+		for name, propType := range types {
+			id := name
+			if propType == "title" {
+				id = "title"
+			}
+			properties[name] = map[string]interface{}{"id": id, "type": propType}
+		}
+		writeJSON(w, map[string]interface{}{"properties": properties})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &updated
+}
+
+func TestValidateDatabase(t *testing.T) {
+	server, updated := fakeSchemaServer(t, map[string]string{
+		"Judul":       "title",
+		"Tanggal":     "date",
+		"Jumlah Kata": "rich_text",
+	})
+
+	service := &SyncService{
+		client:     newTestClient(server),
+		databaseID: "db-1",
+		properties: config.PropertyMap{
+			"date":        "Tanggal",
+			"hfl_date":    "none",
+			"word_count":  "Jumlah Kata",
+			"sync_status": "Status",
+		},
+	}
+
+	problems, err := service.ValidateDatabase()
+	if err != nil {
+		t.Fatalf("ValidateDatabase failed: %v", err)
+	}
+
+	expected := []SchemaProblem{
+		{Field: "word_count", Property: "Jumlah Kata", Expected: "number", Actual: "rich_text"},
+		{Field: "sync_status", Property: "Status", Expected: "select"},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, problems)
+	}
+
+	// Validation alone never changes the schema
+	if len(*updated) != 0 {
+		t.Errorf("Expected no schema changes, got %v", *updated)
+	}
+
+	// Only the missing property is added; the mismatched one is left alone
+	if err := service.AddMissingProperties(problems); err != nil {
+		t.Fatalf("AddMissingProperties failed: %v", err)
+	}
+	if _, ok := (*updated)["Status"]; !ok || len(*updated) != 1 {
+		t.Errorf("Expected only Status to be added, got %v", *updated)
+	}
+}
+
+func TestPush_MappedProperties(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+	service.titleTemplate, service.titleLength = "{sentence}", 60
+	service.properties = config.PropertyMap{
+		"title":      "Judul",
+		"date":       "Tanggal",
+		"hfl_date":   "none",
+		"word_count": "none",
+	}
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Hari yang tenang."}}}
+	if err := engine.Push(journal, newState()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if len(fake.order) != 1 {
+		t.Fatalf("Expected 1 page, got %d", len(fake.order))
+	}
+	page := fake.pages[fake.order[0]]
+
+	var names []string
+	for name := range page.Properties {
+		names = append(names, name)
+	}
+	for _, name := range []string{"Judul", "Tanggal", "Sync Status"} {
+		if _, ok := page.Properties[name]; !ok {
+			t.Errorf("Expected property %q to be written, got %v", name, names)
+		}
+	}
+	if len(page.Properties) != 3 {
+		t.Errorf("Expected disabled fields to be left out, got %v", names)
+	}
+
+	// Pages are found by the mapped date, so a fresh state adopts the page
+	// instead of creating another
+	if err := engine.Push(journal, newState()); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(fake.order) != 1 {
+		t.Errorf("Expected the page to be adopted, got %d pages", len(fake.order))
+	}
+}
@@ -3,260 +3,350 @@ package notion
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/ahmaruff/hfl/internal/config"
 	"github.com/ahmaruff/hfl/internal/parser"
 	"github.com/ahmaruff/hfl/internal/state"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
 )
 
 type SyncService struct {
-	client     *Client
-	databaseID string
+	client        *Client
+	databaseID    string
+	titleTemplate string // no titles are written when empty
+	titleLength   int
+	properties    config.PropertyMap
+	report        []hflsync.ReportItem
+
+	// pagesByDate indexes the database for dedupe
+	pagesByDate map[string][]Page
+	// listed holds the pages last listed, so Get doesn't fetch them again
+	listed map[string]Page
+	cache  *pageCache
 }
 
-func NewSyncService(token, databaseID string) *SyncService {
+func NewSyncService(cfg config.NotionConfig) *SyncService {
+	options := ClientOptions{
+		RequestsPerSecond: cfg.RateLimit,
+		MaxRetries:        cfg.MaxRetries,
+	}
+
 	return &SyncService{
-		client:     NewClient(token),
-		databaseID: databaseID,
+		client:        NewClientWithOptions(cfg.ApiToken, options),
+		databaseID:    cfg.DatabaseID,
+		titleTemplate: cfg.GetTitleTemplate(),
+		titleLength:   cfg.GetTitleLength(),
+		properties:    cfg.Properties,
+		cache:         &pageCache{dir: filepath.Join(".hfl", "cache")},
 	}
 }
 
-func (s *SyncService) ValidateAndSetupDatabase() error {
-	db, err := s.client.GetDatabase(s.databaseID)
-	if err != nil {
-		return fmt.Errorf("failed to fetch database: %w", err)
-	}
+// Name implements hflsync.Backend
+func (s *SyncService) Name() string {
+	return "notion"
+}
 
-	props, ok := db["properties"].(map[string]interface{})
-	if !ok {
-		return fmt.Errorf("unexpected schema format")
-	}
-
-	required := map[string]map[string]interface{}{
-		"Date":       {"date": map[string]interface{}{}},
-		"HFL_Date":   {"rich_text": map[string]interface{}{}},
-		"Word Count": {"number": map[string]interface{}{}},
-		"Sync Status": {
-			"select": map[string]interface{}{
-				"options": []map[string]interface{}{
-					{"name": "Pending", "color": "blue"},
-					{"name": "Conflict", "color": "red"},
-					{"name": "Modified", "color": "yellow"},
-					{"name": "Synced", "color": "green"},
-				},
-			},
-		},
-	}
-
-	missing := make(map[string]interface{})
-	for key, schema := range required {
-		if _, exists := props[key]; !exists {
-			missing[key] = schema
+// List returns every live page of the database
+func (s *SyncService) List() ([]hflsync.Document, error) {
+	return s.query(Query{})
+}
+
+// query returns the live pages matching q, and remembers them for Get
+func (s *SyncService) query(q Query) ([]hflsync.Document, error) {
+	var documents []hflsync.Document
+	err := s.client.QueryDatabaseFunc(s.databaseID, q, func(page Page) error {
+		if !page.Archived && !page.InTrash {
+			s.remember(page)
+			documents = append(documents, s.document(page))
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return documents, nil
+}
 
-	if len(missing) > 0 {
-		fmt.Printf("Adding missing properties: %v\n", missing)
-		if err := s.client.UpdateDatabase(s.databaseID, missing); err != nil {
-			return fmt.Errorf("failed to update database schema: %w", err)
-		}
+const (
+	// changesMargin is how far back from the last edit seen a pull starts
+	// looking: Notion rounds last_edited_time down to the minute, and an
+	// edit can take a moment to show up in queries
+	changesMargin = 5 * time.Minute
+	// fullListingEvery is how often a pull lists the whole database
+	// anyway, since a filtered query can't tell which pages were deleted
+	fullListingEvery = 24 * time.Hour
+)
+
+// Changes returns the pages edited since the pull that returned cursor.
+// The first pull, and the first one each day, lists the whole database
+// instead. The cursor holds the last edit time seen and when the database
+// was last listed in full.
+func (s *SyncService) Changes(cursor string) (hflsync.Changes, error) {
+	now := time.Now().UTC()
+	since, listed := parseCursor(cursor)
+
+	complete := since.IsZero() || now.Sub(listed) > fullListingEvery
+	q := Query{}
+	if complete {
+		listed = now
 	} else {
-		fmt.Println("Database schema is valid")
+		q = Query{Filter: EditedSince(since.Add(-changesMargin)), Sorts: []Sort{SortByEdited(Ascending)}}
 	}
 
-	return nil
-}
-
-// SyncToNotion pushes local changes to Notion
-func (s *SyncService) SyncToNotion(journal *parser.Journal, state *state.State) error {
-	for _, entry := range journal.Entries {
-		entryState, exists := state.GetEntry(entry.Date)
+	documents, err := s.query(q)
+	if err != nil {
+		return hflsync.Changes{}, err
+	}
 
-		if !exists || entryState.NotionID == "" {
-			if err := s.createEntry(entry, state); err != nil {
-				return fmt.Errorf("failed to create entry %s: %w", entry.Date, err)
-			}
-		} else if state.HasChanged(entry.Date, entry.Body) {
-			if err := s.updateEntry(entry, entryState, state); err != nil {
-				return fmt.Errorf("failed to update entry %s: %w", entry.Date, err)
-			}
+	for _, document := range documents {
+		if document.EditedAt.After(since) {
+			since = document.EditedAt
 		}
 	}
+	if since.IsZero() {
+		since = now
+	}
 
-	// Update last sync time
-	state.SetLastSynced(time.Now().Format(time.RFC3339))
-	return state.Save()
+	return hflsync.Changes{
+		Documents: documents,
+		Cursor:    since.UTC().Format(time.RFC3339) + " " + listed.Format(time.RFC3339),
+		Complete:  complete,
+	}, nil
 }
 
-// SyncFromNotion pulls remote changes from Notion
-func (s *SyncService) SyncFromNotion(journal *parser.Journal, state *state.State) error {
-	// Query all entries from Notion
-	response, err := s.client.QueryDatabase(s.databaseID, nil)
+// parseCursor reads a cursor returned by Changes. An empty or unreadable
+// one gives zero times.
+func parseCursor(cursor string) (time.Time, time.Time) {
+	parts := strings.Fields(cursor)
+	if len(parts) != 2 {
+		return time.Time{}, time.Time{}
+	}
+
+	since, err := time.Parse(time.RFC3339, parts[0])
+	if err != nil {
+		return time.Time{}, time.Time{}
+	}
+	listed, err := time.Parse(time.RFC3339, parts[1])
 	if err != nil {
-		return fmt.Errorf("failed to query database: %w", err)
+		return time.Time{}, time.Time{}
 	}
+	return since, listed
+}
 
-	fmt.Printf("Found %d pages in Notion database\n", len(response.Results))
+// remember keeps a page seen in a listing, so Get can use it
+func (s *SyncService) remember(page Page) {
+	if s.listed == nil {
+		s.listed = make(map[string]Page)
+	}
+	s.listed[page.ID] = page
+}
 
-	for _, page := range response.Results {
-		date := s.extractDate(page)
-		if date == "" {
-			continue
+// Get returns a page with its body rendered as Markdown. A page from the
+// last listing isn't fetched again, and neither is the body of a page
+// that didn't change since it was cached.
+func (s *SyncService) Get(id string) (hflsync.Document, error) {
+	page, ok := s.listed[id]
+	if !ok {
+		fetched, err := s.client.GetPage(id)
+		if IsNotFound(err) {
+			return hflsync.Document{}, fmt.Errorf("%w: %w", hflsync.ErrNotFound, err)
 		}
-
-		// Get content from page blocks only
-		content, err := s.getPageContent(page.ID)
 		if err != nil {
-			fmt.Printf("Warning: failed to get content for %s: %v\n", date, err)
-			continue
+			return hflsync.Document{}, err
 		}
+		page = *fetched
+	}
 
-		// Check if we need to update local entry
-		entryState, exists := state.GetEntry(date)
-		if !exists || s.shouldUpdateLocal(page, entryState) {
-			if err := s.updateLocalEntry(journal, date, content, page, state); err != nil {
-				return fmt.Errorf("failed to update local entry %s: %w", date, err)
-			}
-		}
+	document := s.document(page)
+	if document.Deleted {
+		return document, nil
 	}
 
-	fmt.Printf("Sync from Notion done\n")
-	return nil
-}
+	if body, ok := s.cache.get(page); ok {
+		document.Body = body
+		return document, nil
+	}
 
-// extractNoteProperty: HAPUS atau RENAME jika tidak dipakai
-// (Tidak dipakai di kode, jadi bisa dihapus)
-// func (s *SyncService) extractNoteProperty(page Page) string { ... }
+	body, err := s.getPageContent(id)
+	if err != nil {
+		return hflsync.Document{}, err
+	}
+	s.cache.put(page, body)
 
-func (s *SyncService) createEntry(entry parser.Entry, state *state.State) error {
-	wordCount := float64(len(strings.Fields(entry.Body)))
+	document.Body = body
+	return document, nil
+}
+
+// Create adds a page for an entry to the database
+func (s *SyncService) Create(entry parser.Entry) (hflsync.Document, error) {
+	properties := s.metadata(entry.Body, hflsync.StatusSynced)
+	s.setProperty(properties, "date", NewDateProperty(entry.Date))
+	s.setProperty(properties, "hfl_date", NewPlainRichTextProperty(entry.Date))
 
-	properties := Properties{
-		"Date":        NewDateProperty(entry.Date),
-		"HFL_Date":    NewPlainRichTextProperty(entry.Date),
-		"Word Count":  NewNumberProperty(wordCount),
-		"Sync Status": NewSelectProperty("Synced"),
+	title := s.title(entry)
+	if title != "" {
+		s.setProperty(properties, "title", NewTitleProperty(title))
 	}
 
 	blocks := MarkdownToBlocks(entry.Body)
 
 	page, err := s.client.CreatePage(s.databaseID, properties, blocks)
 	if err != nil {
-		return err
+		return hflsync.Document{}, err
 	}
 
-	// Update state
-	state.SetNotionID(entry.Date, page.ID)
-	state.UpdateEntry(entry.Date, entry.Body)
-
-	return nil
+	document := s.stored(*page, BlocksToMarkdown(blocks))
+	document.Title = title
+	return document, nil
 }
 
-func (s *SyncService) updateEntry(entry parser.Entry, entryState state.EntryState, state *state.State) error {
-	if entryState.NotionID == "" {
-		return fmt.Errorf("no Notion ID for entry %s", entry.Date)
+// Update replaces the content of a page, and its title when it follows
+// the body. Archived and missing pages wrap hflsync.ErrNotFound.
+func (s *SyncService) Update(id string, entry parser.Entry, previous state.EntryState) (hflsync.Document, error) {
+	document, err := s.update(id, entry, previous)
+	if IsNotFound(err) || IsArchived(err) {
+		return hflsync.Document{}, fmt.Errorf("%w: %w", hflsync.ErrNotFound, err)
 	}
+	return document, err
+}
 
-	// Update metadata properties
-	wordCount := float64(len(strings.Fields(entry.Body)))
-	properties := Properties{
-		"Word Count":  NewNumberProperty(wordCount),
-		"Sync Status": NewSelectProperty("Synced"),
+func (s *SyncService) update(id string, entry parser.Entry, previous state.EntryState) (hflsync.Document, error) {
+	// Update page content
+	blocks := MarkdownToBlocks(entry.Body)
+	if err := s.client.UpdateBlockChildren(id, blocks); err != nil {
+		return hflsync.Document{}, err
 	}
 
-	_, err := s.client.UpdatePage(entryState.NotionID, properties)
+	// Update metadata properties last, so the returned page carries the
+	// final last-edited time
+	properties := s.metadata(entry.Body, hflsync.StatusSynced)
+
+	title, retitled, err := s.retitle(entry, previous)
 	if err != nil {
-		return err
+		return hflsync.Document{}, err
+	}
+	if retitled {
+		s.setProperty(properties, "title", NewTitleProperty(title))
+	} else {
+		title = previous.Title
 	}
 
-	// Update page content
-	blocks := MarkdownToBlocks(entry.Body)
-	if err := s.client.UpdateBlockChildren(entryState.NotionID, blocks); err != nil {
-		return err
+	page, err := s.client.UpdatePage(id, properties)
+	if err != nil {
+		return hflsync.Document{}, err
 	}
 
-	// Update state
-	state.UpdateEntry(entry.Date, entry.Body)
+	document := s.stored(*page, BlocksToMarkdown(blocks))
+	document.Title = title
+	return document, nil
+}
+
+// Delete archives a page. A page that is already gone wraps
+// hflsync.ErrNotFound.
+func (s *SyncService) Delete(id string) error {
+	delete(s.listed, id)
+	s.cache.remove(id)
 
-	return nil
+	err := s.client.ArchivePage(id)
+	if IsNotFound(err) {
+		return fmt.Errorf("%w: %w", hflsync.ErrNotFound, err)
+	}
+	return err
 }
 
-// getPageContent: FIXED — baca semua blok dengan benar
-func (s *SyncService) getPageContent(pageID string) (string, error) {
-	blocks, err := s.client.GetBlockChildren(pageID)
+// Normalize renders body the way it reads back from Notion
+func (s *SyncService) Normalize(body string) string {
+	return BlocksToMarkdown(MarkdownToBlocks(body))
+}
+
+// MarkStatus writes the sync status and word count properties of a page
+func (s *SyncService) MarkStatus(id, body, status string) (hflsync.Document, error) {
+	properties := s.metadata(body, status)
+	if status == hflsync.StatusConflict {
+		// The word count follows the body once the conflict is resolved
+		properties = Properties{}
+		s.setProperty(properties, "sync_status", NewSelectProperty(status))
+	}
+	if len(properties) == 0 {
+		return hflsync.Document{ID: id}, nil
+	}
+
+	page, err := s.client.UpdatePage(id, properties)
 	if err != nil {
-		return "", fmt.Errorf("failed to get block children: %w", err)
+		return hflsync.Document{}, err
 	}
+	return s.stored(*page, body), nil
+}
 
-	var content strings.Builder
+// document describes a page without its body
+func (s *SyncService) document(page Page) hflsync.Document {
+	return hflsync.Document{
+		ID:       page.ID,
+		Date:     s.extractDate(page),
+		EditedAt: page.LastEditedTime,
+		Deleted:  page.Archived || page.InTrash,
+	}
+}
 
-	for i, block := range blocks.Results {
-		var textObjects []TextObject
+// stored describes a page hfl just wrote with the given body, which is
+// cached and kept for Get
+func (s *SyncService) stored(page Page, body string) hflsync.Document {
+	if _, ok := s.listed[page.ID]; ok {
+		s.listed[page.ID] = page
+	}
+	s.cache.put(page, body)
 
-		switch block.Type {
-		case "paragraph":
-			if block.Paragraph != nil {
-				// Loop semua RichText, lalu ambil TextObject-nya
-				for _, rt := range block.Paragraph.RichText {
-					textObjects = append(textObjects, rt)
-				}
-			}
-		case "heading_1":
-			if block.Heading1 != nil {
-				for _, rt := range block.Heading1.RichText {
-					textObjects = append(textObjects, rt)
-				}
-			}
-		case "heading_2":
-			if block.Heading2 != nil {
-				for _, rt := range block.Heading2.RichText {
-					textObjects = append(textObjects, rt)
-				}
-			}
-		case "heading_3":
-			if block.Heading3 != nil {
-				for _, rt := range block.Heading3.RichText {
-					textObjects = append(textObjects, rt)
-				}
-			}
-		case "bulleted_list_item":
-			if block.BulletedListItem != nil {
-				for _, rt := range block.BulletedListItem.RichText {
-					textObjects = append(textObjects, rt)
-				}
-			}
-		case "numbered_list_item":
-			if block.NumberedListItem != nil {
-				for _, rt := range block.NumberedListItem.RichText {
-					textObjects = append(textObjects, rt)
-				}
-			}
-		default:
-			fmt.Printf("Unsupported block type: %s (skipping)\n", block.Type)
-			continue
-		}
+	document := s.document(page)
+	document.Body = body
+	return document
+}
 
-		// Ekstrak teks dari textObjects
-		for _, obj := range textObjects {
-			if obj.Text != nil {
-				content.WriteString(obj.Text.Content)
-			}
-		}
+// PageContent returns the body of a Notion page rendered as Markdown
+func (s *SyncService) PageContent(pageID string) (string, error) {
+	return s.getPageContent(pageID)
+}
 
-		// Tambahkan spasi antar blok
-		if i < len(blocks.Results)-1 {
-			switch block.Type {
-			case "paragraph", "heading_1", "heading_2", "heading_3":
-				content.WriteString("\n\n")
-			case "bulleted_list_item", "numbered_list_item":
-				content.WriteString("\n")
-			}
+// indexPages groups the live pages of the database by date. The database
+// is read once and the index kept until it is reset.
+func (s *SyncService) indexPages() (map[string][]Page, error) {
+	if s.pagesByDate != nil {
+		return s.pagesByDate, nil
+	}
+
+	pagesByDate := make(map[string][]Page)
+	err := s.client.QueryDatabaseFunc(s.databaseID, Query{}, func(page Page) error {
+		if page.Archived || page.InTrash {
+			return nil
 		}
+		if date := s.extractDate(page); date != "" {
+			pagesByDate[date] = append(pagesByDate[date], page)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.pagesByDate = pagesByDate
+	return pagesByDate, nil
+}
+
+// extractNoteProperty: HAPUS atau RENAME jika tidak dipakai
+// (Tidak dipakai di kode, jadi bisa dihapus)
+// func (s *SyncService) extractNoteProperty(page Page) string { ... }
+
+// getPageContent reads the whole block tree of a page and renders it as
+// Markdown
+func (s *SyncService) getPageContent(pageID string) (string, error) {
+	blocks, err := s.client.GetBlockTree(pageID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get block children: %w", err)
 	}
 
-	finalContent := strings.TrimSpace(content.String())
-	return finalContent, nil
+	return BlocksToMarkdown(blocks), nil
 }
 
 // extractDate: FIXED — akses HFL_Date dengan benar
@@ -268,7 +358,7 @@ func (s *SyncService) extractDate(page Page) string {
 	}
 
 	// Try HFL_Date first (more reliable)
-	if prop, ok := page.Properties["HFL_Date"]; ok && len(prop.RichText) > 0 {
+	if prop, ok := page.Properties[s.property("hfl_date")]; ok && len(prop.RichText) > 0 {
 		textObj := prop.RichText[0]
 		if textObj.Text != nil {
 			content := strings.TrimSpace(textObj.Text.Content)
@@ -279,7 +369,7 @@ func (s *SyncService) extractDate(page Page) string {
 	}
 
 	// Fallback to Date property
-	if dateProp, ok := page.Properties["Date"]; ok && dateProp.Date != nil {
+	if dateProp, ok := page.Properties[s.property("date")]; ok && dateProp.Date != nil {
 		dateStr := strings.Split(dateProp.Date.Start, "T")[0]
 		if isValidDate(dateStr) {
 			return dateStr
@@ -288,52 +378,3 @@ func (s *SyncService) extractDate(page Page) string {
 
 	return ""
 }
-
-func (s *SyncService) shouldUpdateLocal(page Page, entryState state.EntryState) bool {
-	if entryState.LastLocalSync == "" {
-		return true
-	}
-
-	localTime, err := time.Parse(time.RFC3339, entryState.LastLocalSync)
-	if err != nil {
-		return true
-	}
-
-	return page.LastEditedTime.After(localTime)
-}
-
-func (s *SyncService) updateLocalEntry(journal *parser.Journal, date, content string, page Page, state *state.State) error {
-	found := false
-	for i, entry := range journal.Entries {
-		if entry.Date == date {
-			journal.Entries[i].Body = content
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		newEntry := parser.Entry{
-			Date: date,
-			Body: content,
-		}
-		journal.Entries = append(journal.Entries, newEntry)
-	}
-
-	// Update Notion metadata
-	wordCount := float64(len(strings.Fields(content)))
-	properties := Properties{
-		"Word Count":  NewNumberProperty(wordCount),
-		"Sync Status": NewSelectProperty("Synced"),
-	}
-
-	if _, err := s.client.UpdatePage(page.ID, properties); err != nil {
-		fmt.Printf("Warning: failed to update metadata for %s: %v\n", date, err)
-	}
-
-	// Update state
-	state.SetNotionID(date, page.ID)
-	state.UpdateEntry(date, content)
-
-	return nil
-}
@@ -0,0 +1,737 @@
+package notion
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ahmaruff/hfl/internal/merge"
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+// chdirTemp runs the test in an empty directory so state files stay isolated
+func chdirTemp(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	t.Cleanup(func() { os.Chdir(originalDir) })
+}
+
+func newState() *state.State {
+	return &state.State{Entries: make(map[string]state.EntryState)}
+}
+
+func TestPush_RecordsRemoteEdit(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First entry."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	entry := syncState.Entries["2025-08-16"]
+	if entry.RemoteID == "" {
+		t.Fatal("Expected notion_id to be recorded")
+	}
+
+	page := fake.pages[entry.RemoteID]
+	if entry.LastRemoteEdit != page.LastEditedTime.UTC().Format(time.RFC3339) {
+		t.Errorf("Expected last_remote_edit %s, got %q", page.LastEditedTime.UTC().Format(time.RFC3339), entry.LastRemoteEdit)
+	}
+
+	if entry.RemoteHash != state.HashContent("First entry.") {
+		t.Error("Expected remote_hash to match the pushed content")
+	}
+
+	// A pull right after the push has nothing to fetch
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	if n := fake.count("GET", "/blocks/"); n != 0 {
+		t.Errorf("Expected no block requests for unchanged pages, got %d", n)
+	}
+}
+
+func TestPull_PullsRemoteEdit(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First entry."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	fake.editBody(syncState.Entries["2025-08-16"].RemoteID, "Edited in Notion.")
+
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	if journal.Entries[0].Body != "Edited in Notion." {
+		t.Errorf("Expected remote edit to be pulled, got %q", journal.Entries[0].Body)
+	}
+
+	entry := syncState.Entries["2025-08-16"]
+	if entry.RemoteHash != state.HashContent("Edited in Notion.") {
+		t.Error("Expected remote_hash to follow the pulled content")
+	}
+
+	if syncState.HasChanged("2025-08-16", journal.Entries[0].Body) {
+		t.Error("Expected pulled entry to be in sync")
+	}
+}
+
+func TestDetectConflicts(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-16", Body: "Both sides."},
+		{Date: "2025-08-15", Body: "Local only."},
+		{Date: "2025-08-14", Body: "Remote only."},
+	}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	journal.Entries[0].Body = "Both sides, edited locally."
+	journal.Entries[1].Body = "Local only, edited."
+	fake.editBody(syncState.Entries["2025-08-16"].RemoteID, "Both sides, edited remotely.")
+	fake.editBody(syncState.Entries["2025-08-14"].RemoteID, "Remote only, edited.")
+
+	conflicts, err := engine.DetectConflicts(journal, syncState)
+	if err != nil {
+		t.Fatalf("DetectConflicts failed: %v", err)
+	}
+
+	if len(conflicts) != 1 {
+		t.Fatalf("Expected 1 conflict, got %v", conflicts)
+	}
+
+	if conflicts[0].Date != "2025-08-16" {
+		t.Errorf("Expected conflict on 2025-08-16, got %s", conflicts[0].Date)
+	}
+
+	page := fake.pages[conflicts[0].RemoteID]
+	if status := page.Properties["Sync Status"].Select; status == nil || status.Name != "Conflict" {
+		t.Errorf("Expected Sync Status to be Conflict, got %v", status)
+	}
+}
+
+func TestDetectConflicts_PropertyEditIsNotConflict(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Entry."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Only a property changes in Notion; the body stays the same
+	pageID := syncState.Entries["2025-08-16"].RemoteID
+	if _, err := service.client.UpdatePage(pageID, Properties{"Word Count": NewNumberProperty(42)}); err != nil {
+		t.Fatal(err)
+	}
+
+	journal.Entries[0].Body = "Entry, edited locally."
+
+	conflicts, err := engine.DetectConflicts(journal, syncState)
+	if err != nil {
+		t.Fatalf("DetectConflicts failed: %v", err)
+	}
+
+	if len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts for a property-only edit, got %v", conflicts)
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-16", Body: "Morning.\n\nNoon.\n\nEvening."},
+		{Date: "2025-08-15", Body: "The weather was fine."},
+	}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Non-overlapping edits on 16th, overlapping edits on 15th
+	journal.Entries[0].Body = "Morning run.\n\nNoon.\n\nEvening."
+	fake.editBody(syncState.Entries["2025-08-16"].RemoteID, "Morning.\n\nNoon.\n\nEvening tea.")
+	journal.Entries[1].Body = "The weather was sunny."
+	fake.editBody(syncState.Entries["2025-08-15"].RemoteID, "The weather was cloudy.")
+
+	conflicts, err := engine.DetectConflicts(journal, syncState)
+	if err != nil {
+		t.Fatalf("DetectConflicts failed: %v", err)
+	}
+
+	unresolved, err := engine.MergeConflicts(journal, syncState, conflicts)
+	if err != nil {
+		t.Fatalf("MergeConflicts failed: %v", err)
+	}
+
+	if len(unresolved) != 1 || unresolved[0] != "2025-08-15" {
+		t.Errorf("Expected 2025-08-15 to stay unresolved, got %v", unresolved)
+	}
+
+	merged := "Morning run.\n\nNoon.\n\nEvening tea."
+	if journal.Entries[0].Body != merged {
+		t.Errorf("Expected clean merge %q, got %q", merged, journal.Entries[0].Body)
+	}
+
+	if !merge.HasConflictMarkers(journal.Entries[1].Body) {
+		t.Errorf("Expected conflict markers, got %q", journal.Entries[1].Body)
+	}
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// The merged entry is pushed, the one with markers is held back
+	content, _ := service.getPageContent(syncState.Entries["2025-08-16"].RemoteID)
+	if content != merged {
+		t.Errorf("Expected merged body in Notion, got %q", content)
+	}
+
+	content, _ = service.getPageContent(syncState.Entries["2025-08-15"].RemoteID)
+	if content != "The weather was cloudy." {
+		t.Errorf("Expected conflicted entry not to be pushed, got %q", content)
+	}
+}
+
+func TestUnresolvedConflictIsSkipped(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Original."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	pageID := syncState.Entries["2025-08-16"].RemoteID
+	journal.Entries[0].Body = "Local edit."
+	fake.editBody(pageID, "Remote edit.")
+
+	if _, err := engine.DetectConflicts(journal, syncState); err != nil {
+		t.Fatalf("DetectConflicts failed: %v", err)
+	}
+
+	if conflicts := syncState.Conflicts(); len(conflicts) != 1 {
+		t.Fatalf("Expected conflict to be recorded in state, got %v", conflicts)
+	}
+
+	// Neither direction touches the entry until it is resolved
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if journal.Entries[0].Body != "Local edit." {
+		t.Errorf("Expected local body to be kept, got %q", journal.Entries[0].Body)
+	}
+
+	if content, _ := service.PageContent(pageID); content != "Remote edit." {
+		t.Errorf("Expected remote body to be kept, got %q", content)
+	}
+
+	// Keeping the remote version pulls just that entry
+	syncState.Resolve("2025-08-16", state.ResolutionRemote)
+	if err := engine.PullEntry(journal, syncState, "2025-08-16"); err != nil {
+		t.Fatalf("PullEntry failed: %v", err)
+	}
+
+	if journal.Entries[0].Body != "Remote edit." {
+		t.Errorf("Expected remote body to be pulled, got %q", journal.Entries[0].Body)
+	}
+
+	if len(syncState.Conflicts()) != 0 {
+		t.Error("Expected conflict to be cleared after pulling")
+	}
+}
+
+func TestArchiveEntry(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-16", Body: "Kept."},
+		{Date: "2025-08-15", Body: "Deleted."},
+	}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	pageID := syncState.Entries["2025-08-15"].RemoteID
+	journal.Entries = journal.Entries[:1]
+
+	tombstones := syncState.RecordDeletions(journal)
+	if len(tombstones) != 1 {
+		t.Fatalf("Expected 1 tombstone, got %v", tombstones)
+	}
+
+	// A pull doesn't bring the deleted entry back
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(journal.Entries) != 1 {
+		t.Errorf("Expected deleted entry to stay deleted, got %d entries", len(journal.Entries))
+	}
+
+	if err := engine.Archive(syncState, "2025-08-15"); err != nil {
+		t.Fatalf("ArchiveEntry failed: %v", err)
+	}
+
+	if !fake.pages[pageID].Archived {
+		t.Error("Expected page to be archived in Notion")
+	}
+
+	if _, exists := syncState.GetEntry("2025-08-15"); exists {
+		t.Error("Expected state entry to be removed after archiving")
+	}
+}
+
+func TestArchiveEntry_FailureKeepsState(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+	retries := 1
+	service.client.options.MaxRetries = &retries
+	service.client.options.BaseDelay = time.Millisecond
+
+	syncState := newState()
+	syncState.UpdateEntry("2025-08-15", "Deleted.")
+	syncState.SetRemoteID("2025-08-15", "page-1")
+
+	fake.server.Close() // Notion is unreachable
+
+	if err := engine.Archive(syncState, "2025-08-15"); err == nil {
+		t.Fatal("Expected ArchiveEntry to fail")
+	}
+
+	if _, exists := syncState.GetEntry("2025-08-15"); !exists {
+		t.Error("Expected state entry to be kept when archiving fails")
+	}
+}
+
+func TestPull_RemoteDeletePolicy(t *testing.T) {
+	tests := []struct {
+		policy  string
+		action  string
+		entries int
+	}{
+		{"unlink", hflsync.ActionUnlinked, 2},
+		{"delete", hflsync.ActionDeleted, 1},
+		{"recreate", hflsync.ActionRecreate, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			chdirTemp(t)
+			fake := newFakeNotion(t)
+			service := fake.service()
+			engine := hflsync.NewEngine(service, hflsync.Options{OnRemoteDelete: tt.policy})
+
+			journal := &parser.Journal{Entries: []parser.Entry{
+				{Date: "2025-08-16", Body: "Kept."},
+				{Date: "2025-08-15", Body: "Archived in Notion."},
+			}}
+			syncState := newState()
+
+			if err := engine.Push(journal, syncState); err != nil {
+				t.Fatalf("Push failed: %v", err)
+			}
+
+			pageID := syncState.Entries["2025-08-15"].RemoteID
+			fake.pages[pageID].InTrash = true
+
+			if err := engine.Pull(journal, syncState); err != nil {
+				t.Fatalf("Pull failed: %v", err)
+			}
+
+			if len(journal.Entries) != tt.entries {
+				t.Errorf("Expected %d entries, got %d", tt.entries, len(journal.Entries))
+			}
+
+			report := engine.Report()
+			last := report[len(report)-1]
+			if last.Date != "2025-08-15" || last.Action != tt.action {
+				t.Errorf("Expected %s of 2025-08-15 in report, got %+v", tt.action, last)
+			}
+
+			// The next push must not touch the trashed page
+			if err := engine.Push(journal, syncState); err != nil {
+				t.Fatalf("Push after remote delete failed: %v", err)
+			}
+
+			entry, exists := syncState.GetEntry("2025-08-15")
+			switch tt.policy {
+			case "unlink":
+				if !entry.Unlinked || entry.RemoteID != "" {
+					t.Errorf("Expected entry to be unlinked, got %+v", entry)
+				}
+			case "delete":
+				if exists {
+					t.Error("Expected entry to be removed from state")
+				}
+			case "recreate":
+				if entry.RemoteID == "" || entry.RemoteID == pageID {
+					t.Errorf("Expected a new page to be created, got %q", entry.RemoteID)
+				}
+			}
+		})
+	}
+}
+
+func TestPull_DeleteKeepsLocalEdits(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{OnRemoteDelete: "delete"})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First entry."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	fake.pages[syncState.Entries["2025-08-16"].RemoteID].Archived = true
+	journal.Entries[0].Body = "Edited locally."
+
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	if len(journal.Entries) != 1 {
+		t.Fatal("Expected locally edited entry to be kept")
+	}
+	if entry := syncState.Entries["2025-08-16"]; !entry.Unlinked {
+		t.Errorf("Expected entry to be unlinked instead, got %+v", entry)
+	}
+}
+
+func TestPush_ArchivedPageIsSkipped(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First entry."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	fake.pages[syncState.Entries["2025-08-16"].RemoteID].Archived = true
+	journal.Entries[0].Body = "Edited locally."
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Expected push to skip the archived page, got %v", err)
+	}
+
+	report := engine.Report()
+	if last := report[len(report)-1]; last.Action != hflsync.ActionSkipped {
+		t.Errorf("Expected skipped entry in report, got %+v", last)
+	}
+}
+
+func TestPush_SavesAfterEachEntry(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	fake.createLimit = 2
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-16", Body: "One."},
+		{Date: "2025-08-15", Body: "Two."},
+		{Date: "2025-08-14", Body: "Three."},
+	}}
+
+	if err := engine.Push(journal, newState()); err == nil {
+		t.Fatal("Expected the third create to fail")
+	}
+
+	saved, err := state.Load("notion")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, date := range []string{"2025-08-16", "2025-08-15"} {
+		if saved.Entries[date].RemoteID == "" {
+			t.Errorf("Expected page ID of %s to be saved before the failure", date)
+		}
+	}
+
+	if saved.Entries["2025-08-14"].PendingCreate == "" {
+		t.Error("Expected failed create to stay pending")
+	}
+
+	// Rerunning creates only the missing page
+	fake.createLimit = 0
+	if err := engine.Push(journal, saved); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if len(fake.pages) != 3 {
+		t.Errorf("Expected 3 pages, got %d", len(fake.pages))
+	}
+	if saved.Entries["2025-08-14"].PendingCreate != "" {
+		t.Error("Expected pending marker to be cleared")
+	}
+}
+
+func TestPush_RecoversInterruptedCreate(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	// The page was created, but the process died before saving its ID
+	pageID := fake.addPage("2025-08-16", "First entry.")
+	syncState := newState()
+	syncState.MarkPendingCreate("2025-08-16")
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First entry, edited."}}}
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if fake.count("POST", "/pages") != 0 {
+		t.Error("Expected no duplicate page to be created")
+	}
+
+	entry := syncState.Entries["2025-08-16"]
+	if entry.RemoteID != pageID || entry.PendingCreate != "" {
+		t.Errorf("Expected entry to be linked to the existing page, got %+v", entry)
+	}
+
+	content, err := service.PageContent(pageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "First entry, edited." {
+		t.Errorf("Expected current body to be pushed, got %q", content)
+	}
+}
+
+func TestPush_AdoptsExistingPage(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	// Synced from another machine; this one has no state
+	pageID := fake.addPage("2025-08-16", "First entry.")
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First entry."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if fake.count("POST", "/pages") != 0 {
+		t.Error("Expected the existing page to be adopted, not duplicated")
+	}
+
+	entry := syncState.Entries["2025-08-16"]
+	if entry.RemoteID != pageID || entry.Conflict {
+		t.Errorf("Expected entry to be linked in sync, got %+v", entry)
+	}
+	if syncState.HasChanged("2025-08-16", journal.Entries[0].Body) {
+		t.Error("Expected adopted entry to be in sync")
+	}
+}
+
+func TestAdoptExisting_DifferentBodyIsConflict(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	pageID := fake.addPage("2025-08-16", "Written in Notion.")
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Written locally."}}}
+	syncState := newState()
+
+	if err := engine.AdoptExisting(journal, syncState); err != nil {
+		t.Fatalf("AdoptExisting failed: %v", err)
+	}
+
+	entry := syncState.Entries["2025-08-16"]
+	if entry.RemoteID != pageID || !entry.Conflict {
+		t.Errorf("Expected entry to be linked as a conflict, got %+v", entry)
+	}
+
+	// Neither side overwrites the other until the conflict is resolved
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if journal.Entries[0].Body != "Written locally." {
+		t.Errorf("Expected local body to be kept, got %q", journal.Entries[0].Body)
+	}
+	if content, _ := service.PageContent(pageID); content != "Written in Notion." {
+		t.Errorf("Expected remote body to be kept, got %q", content)
+	}
+}
+
+func TestRelink(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-16", Body: "One."},
+		{Date: "2025-08-15", Body: "Two."},
+	}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	pageIDs := map[string]string{
+		"2025-08-16": syncState.Entries["2025-08-16"].RemoteID,
+		"2025-08-15": syncState.Entries["2025-08-15"].RemoteID,
+	}
+
+	// Stale state pointing at pages that no longer exist
+	syncState.SetRemoteID("2025-08-16", "page-gone")
+	syncState.Unlink("2025-08-15")
+
+	if err := engine.Relink(journal, syncState); err != nil {
+		t.Fatalf("Relink failed: %v", err)
+	}
+
+	for _, date := range []string{"2025-08-16", "2025-08-15"} {
+		entry := syncState.Entries[date]
+		if entry.RemoteID != pageIDs[date] || entry.Unlinked || entry.Conflict {
+			t.Errorf("Expected %s to be relinked to its page, got %+v", date, entry)
+		}
+	}
+
+	if fake.count("POST", "/pages") != 2 {
+		t.Error("Expected relink not to create pages")
+	}
+}
+
+func TestChanges_OnlyQueriesEditedPages(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	older := fake.addPage("2025-08-14", "Older.")
+	fake.now = fake.now.Add(time.Hour)
+	edited := fake.addPage("2025-08-15", "Before.")
+
+	first, err := fake.service().Changes("")
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	if len(first.Documents) != 2 || !first.Complete {
+		t.Fatalf("Expected the first pull to list every page, got %+v", first)
+	}
+
+	fake.now = fake.now.Add(time.Hour)
+	fake.editBody(edited, "After.")
+
+	changes, err := fake.service().Changes(first.Cursor)
+	if err != nil {
+		t.Fatalf("Changes failed: %v", err)
+	}
+	if len(changes.Documents) != 1 || changes.Documents[0].ID != edited || changes.Complete {
+		t.Fatalf("Expected only the edited page, as a partial listing, got %+v", changes)
+	}
+
+	// A partial listing is no reason to treat the other entries as deleted
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-14", Body: "Older."}}}
+	syncState := newState()
+	syncState.SetRemoteID("2025-08-14", older)
+	syncState.UpdateEntry("2025-08-14", "Older.")
+	syncState.Cursor = first.Cursor
+
+	if err := hflsync.NewEngine(fake.service(), hflsync.Options{}).Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(journal.Entries) != 2 || journal.Entries[1].Body != "After." || syncState.Entries["2025-08-14"].Unlinked {
+		t.Errorf("Expected only the edit to be pulled, got %+v", journal.Entries)
+	}
+}
+
+func TestGet_UsesPageCache(t *testing.T) {
+	fake := newFakeNotion(t)
+	id := fake.addPage("2025-08-16", "Cached body.")
+	cache := &pageCache{dir: t.TempDir()}
+
+	// newService lists the database like a pull does
+	newService := func() *SyncService {
+		service := fake.service()
+		service.cache = cache
+		if _, err := service.List(); err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		return service
+	}
+
+	if _, err := newService().Get(id); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	pages, blocks := fake.count("GET", "/pages/"), fake.count("GET", "/blocks/")
+
+	document, err := newService().Get(id)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if document.Body != "Cached body." {
+		t.Errorf("Expected the cached body, got %q", document.Body)
+	}
+	if fake.count("GET", "/pages/") != pages || fake.count("GET", "/blocks/") != blocks {
+		t.Error("Expected an unchanged page not to be fetched again")
+	}
+
+	fake.editBody(id, "Edited in Notion.")
+	if document, _ := newService().Get(id); document.Body != "Edited in Notion." {
+		t.Errorf("Expected an edited page to be fetched, got %q", document.Body)
+	}
+}
@@ -0,0 +1,106 @@
+package notion
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ahmaruff/hfl/internal/markdown"
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+)
+
+// Title renders a page title from template, replacing {date} with the
+// entry's date and {sentence} with its first sentence, cut to length
+// characters. An empty title falls back to the date.
+func Title(template string, length int, entry parser.Entry) string {
+	sentence := truncate(firstSentence(entry.Body), length)
+	title := strings.NewReplacer("{date}", entry.Date, "{sentence}", sentence).Replace(template)
+
+	// Separators left dangling by an empty sentence go too
+	title = strings.Trim(title, " -–—:|·")
+	if title == "" {
+		return entry.Date
+	}
+	return title
+}
+
+// firstSentence returns the first sentence of the first block with text,
+// without Markdown
+func firstSentence(body string) string {
+	for _, block := range markdown.Parse(body) {
+		if block.Kind == markdown.Code {
+			continue
+		}
+
+		text := strings.Join(strings.Fields(markdown.PlainText(block.Text)), " ")
+		if text == "" {
+			continue
+		}
+
+		for _, end := range []string{". ", "! ", "? "} {
+			if i := strings.Index(text, end); i >= 0 {
+				text = text[:i]
+			}
+		}
+		return strings.TrimRight(text, ".!?")
+	}
+	return ""
+}
+
+// truncate cuts text to at most length characters, at a word boundary
+// when there is one, marking the cut with an ellipsis
+func truncate(text string, length int) string {
+	if utf8.RuneCountInString(text) <= length {
+		return text
+	}
+	if length < 1 {
+		return ""
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:length-1])
+	if runes[length-1] != ' ' {
+		if space := strings.LastIndex(cut, " "); space > 0 {
+			cut = cut[:space]
+		}
+	}
+	return strings.TrimRight(cut, " ,;:") + "…"
+}
+
+// pageTitle returns the plain text of a page's title
+func pageTitle(page Page) string {
+	for _, property := range page.Properties {
+		if property.Type == "title" {
+			return markdown.PlainText(spans(property.Title))
+		}
+	}
+	return ""
+}
+
+// title returns the title for an entry's page, or "" when titles are off
+func (s *SyncService) title(entry parser.Entry) string {
+	if s.titleTemplate == "" || s.property("title") == "" {
+		return ""
+	}
+	return Title(s.titleTemplate, s.titleLength, entry)
+}
+
+// retitle returns the new title for an entry whose body changed. The title
+// is left alone when it is already current, or when it was edited in
+// Notion since hfl last wrote it.
+func (s *SyncService) retitle(entry parser.Entry, entryState state.EntryState) (string, bool, error) {
+	title := s.title(entry)
+	if title == "" || title == entryState.Title {
+		return "", false, nil
+	}
+
+	page, err := s.client.GetPage(entryState.RemoteID)
+	if err != nil {
+		return "", false, err
+	}
+	if pageTitle(*page) != entryState.Title {
+		return "", false, nil
+	}
+
+	return title, true, nil
+}
@@ -0,0 +1,131 @@
+package notion
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ahmaruff/hfl/internal/parser"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+func TestTitle(t *testing.T) {
+	tests := []struct {
+		template string
+		length   int
+		body     string
+		expected string
+	}{
+		{"{sentence}", 60, "Went to the park. It rained.", "Went to the park"},
+		{"{sentence}", 60, "## **Big** day\n\nText.", "Big day"},
+		{"{sentence}", 60, "Line one\nstill the first sentence! Next.", "Line one still the first sentence"},
+		{"{sentence}", 20, "A rather long first sentence about nothing", "A rather long first…"},
+		{"{sentence}", 10, "Supercalifragilistic", "Supercali…"},
+		{"{date}", 60, "Anything.", "2025-08-16"},
+		{"{date} – {sentence}", 60, "Quiet day.", "2025-08-16 – Quiet day"},
+		{"{date} – {sentence}", 60, "", "2025-08-16"},
+		{"{sentence}", 60, "```\ncode\n```\n\n[Link](https://x.y) text", "Link text"},
+	}
+
+	for _, tt := range tests {
+		entry := parser.Entry{Date: "2025-08-16", Body: tt.body}
+		if title := Title(tt.template, tt.length, entry); title != tt.expected {
+			t.Errorf("Title(%q, %q): expected %q, got %q", tt.template, tt.body, tt.expected, title)
+		}
+	}
+}
+
+func TestPush_Titles(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+	service.titleTemplate, service.titleLength = "{sentence}", 60
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First try. More."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	pageID := syncState.Entries["2025-08-16"].RemoteID
+	if title := pageTitle(*fake.pages[pageID]); title != "First try" {
+		t.Fatalf("Expected title 'First try', got %q", title)
+	}
+
+	// The title follows the body
+	journal.Entries[0].Body = "Second try."
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if title := pageTitle(*fake.pages[pageID]); title != "Second try" {
+		t.Errorf("Expected title 'Second try', got %q", title)
+	}
+
+	// An unchanged title isn't checked again
+	fake.requests = nil
+	journal.Entries[0].Body = "Second try. With more."
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if n := fake.count("GET", "/pages/"); n != 0 {
+		t.Errorf("Expected no page fetch, got %d", n)
+	}
+}
+
+func TestPush_KeepsEditedTitle(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+	service.titleTemplate, service.titleLength = "{sentence}", 60
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Generated."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	pageID := syncState.Entries["2025-08-16"].RemoteID
+	fake.pages[pageID].Properties["title"] = NewTitleProperty("My own title")
+
+	journal.Entries[0].Body = "Changed body."
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if title := pageTitle(*fake.pages[pageID]); title != "My own title" {
+		t.Errorf("Expected the edited title to stay, got %q", title)
+	}
+	if content, _ := service.PageContent(pageID); !strings.Contains(content, "Changed body.") {
+		t.Errorf("Expected the body to be pushed, got %q", content)
+	}
+}
+
+func TestAdoptedPageKeepsTitle(t *testing.T) {
+	chdirTemp(t)
+	fake := newFakeNotion(t)
+	service := fake.service()
+	engine := hflsync.NewEngine(service, hflsync.Options{})
+	service.titleTemplate, service.titleLength = "{sentence}", 60
+
+	pageID := fake.addPage("2025-08-16", "Written in Notion.")
+	fake.pages[pageID].Properties["title"] = NewTitleProperty("Notion title")
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Written in Notion."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	journal.Entries[0].Body = "Written in Notion. Then edited here."
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if title := pageTitle(*fake.pages[pageID]); title != "Notion title" {
+		t.Errorf("Expected the adopted page's title to stay, got %q", title)
+	}
+}
@@ -2,9 +2,9 @@
 package notion
 
 import (
-	"regexp"
-	"strings"
 	"time"
+
+	"github.com/ahmaruff/hfl/internal/markdown"
 )
 
 // Core response types
@@ -20,11 +20,41 @@ type Page struct {
 	LastEditedTime time.Time  `json:"last_edited_time"`
 	Properties     Properties `json:"properties"`
 	URL            string     `json:"url"`
+	Archived       bool       `json:"archived,omitempty"`
+	InTrash        bool       `json:"in_trash,omitempty"`
+}
+
+// Database is a database as returned by /databases and /search
+type Database struct {
+	ID       string       `json:"id"`
+	Title    []TextObject `json:"title"`
+	URL      string       `json:"url"`
+	Archived bool         `json:"archived,omitempty"`
+	InTrash  bool         `json:"in_trash,omitempty"`
+}
+
+type SearchResponse struct {
+	Results    []Database `json:"results"`
+	HasMore    bool       `json:"has_more"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+// User is the bot user behind an integration token
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Bot  *Bot   `json:"bot,omitempty"`
+}
+
+type Bot struct {
+	WorkspaceName string `json:"workspace_name,omitempty"`
 }
 
 type BlockListResponse struct {
-	Results []Block `json:"results"`
-	HasMore bool    `json:"has_more"`
+	Results    []Block `json:"results"`
+	HasMore    bool    `json:"has_more"`
+	NextCursor string  `json:"next_cursor"`
 }
 
 // Properties for database pages
@@ -32,6 +62,7 @@ type Properties map[string]Property
 
 type Property struct {
 	Type     string       `json:"type"`
+	Title    []TextObject `json:"title,omitempty"`
 	Date     *DateProp    `json:"date,omitempty"`
 	RichText []TextObject `json:"rich_text,omitempty"`
 	Number   *float64     `json:"number,omitempty"`
@@ -52,6 +83,7 @@ type TextObject struct {
 	Type        string       `json:"type"`
 	Text        *Text        `json:"text,omitempty"`
 	Annotations *Annotations `json:"annotations,omitempty"`
+	PlainText   string       `json:"plain_text,omitempty"` // set by Notion on every type
 }
 
 type Link struct {
@@ -64,16 +96,19 @@ type Text struct {
 }
 
 type Annotations struct {
-	Bold   bool   `json:"bold"`
-	Italic bool   `json:"italic"`
-	Code   bool   `json:"code"`
-	Color  string `json:"color"`
+	Bold          bool   `json:"bold"`
+	Italic        bool   `json:"italic"`
+	Strikethrough bool   `json:"strikethrough"`
+	Underline     bool   `json:"underline"`
+	Code          bool   `json:"code"`
+	Color         string `json:"color"`
 }
 
 // Block types for page content
 type Block struct {
 	ID               string          `json:"id,omitempty"`
 	Type             string          `json:"type"`
+	HasChildren      bool            `json:"has_children,omitempty"`
 	Paragraph        *ParagraphBlock `json:"paragraph,omitempty"`
 	Heading1         *HeadingBlock   `json:"heading_1,omitempty"`
 	Heading2         *HeadingBlock   `json:"heading_2,omitempty"`
@@ -82,18 +117,24 @@ type Block struct {
 	NumberedListItem *ListItemBlock  `json:"numbered_list_item,omitempty"`
 	ToDo             *ToDoBlock      `json:"to_do,omitempty"`
 	Quote            *QuoteBlock     `json:"quote,omitempty"`
+	Toggle           *ToggleBlock    `json:"toggle,omitempty"`
+	Code             *CodeBlock      `json:"code,omitempty"`
+	Callout          *CalloutBlock   `json:"callout,omitempty"`
+	Divider          *DividerBlock   `json:"divider,omitempty"`
 	// Tambahkan tipe lain jika perlu
 }
 
 // Block content types
 type ParagraphBlock struct {
 	RichText []TextObject `json:"rich_text"`
+	Children []Block      `json:"children,omitempty"`
 }
 
 type HeadingBlock struct {
 	RichText []TextObject `json:"rich_text"`
 	Color    string       `json:"color,omitempty"`
 	IsToggle bool         `json:"is_toggle,omitempty"`
+	Children []Block      `json:"children,omitempty"`
 }
 
 type ListItemBlock struct {
@@ -106,191 +147,178 @@ type ToDoBlock struct {
 	RichText []TextObject `json:"rich_text"`
 	Checked  bool         `json:"checked"`
 	Color    string       `json:"color,omitempty"`
+	Children []Block      `json:"children,omitempty"`
 }
 
 type QuoteBlock struct {
 	RichText []TextObject `json:"rich_text"`
 	Color    string       `json:"color,omitempty"`
 	Citation string       `json:"citation,omitempty"`
+	Children []Block      `json:"children,omitempty"`
 }
 
-// ParseTextObjects parses a string with simple Markdown formatting into []TextObject
-func ParseTextObjects(text string) []TextObject {
-	var objects []TextObject
-
-	// Regex untuk [text](url)
-	re := regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
-	parts := re.FindAllStringSubmatchIndex(text, -1)
+type ToggleBlock struct {
+	RichText []TextObject `json:"rich_text"`
+	Color    string       `json:"color,omitempty"`
+	Children []Block      `json:"children,omitempty"`
+}
 
-	if len(parts) == 0 {
-		// Tidak ada link → parsing biasa
-		return []TextObject{
-			{
-				Type: "text",
-				Text: &Text{
-					Content: text,
-				},
-				Annotations: &Annotations{
-					Bold:   false,
-					Italic: false,
-					Code:   false,
-					Color:  "default",
-				},
-			},
-		}
-	}
+type CodeBlock struct {
+	RichText []TextObject `json:"rich_text"`
+	Language string       `json:"language"`
+}
 
-	lastEnd := 0
-	for _, match := range parts {
-		start, end := match[0], match[1]
-		linkTextStart, linkTextEnd := match[2], match[3]
-		urlStart, urlEnd := match[4], match[5]
+type CalloutBlock struct {
+	RichText []TextObject `json:"rich_text"`
+	Icon     *Icon        `json:"icon,omitempty"`
+	Color    string       `json:"color,omitempty"`
+	Children []Block      `json:"children,omitempty"`
+}
 
-		// Teks sebelum link
-		if start > lastEnd {
-			plain := text[lastEnd:start]
-			objects = append(objects, TextObject{
-				Type: "text",
-				Text: &Text{
-					Content: plain,
-				},
-				Annotations: &Annotations{
-					Bold:   strings.Contains(plain, "**"),
-					Italic: strings.Contains(plain, "*"),
-					Code:   strings.HasPrefix(plain, "`") && strings.HasSuffix(plain, "`"),
-					Color:  "default",
-				},
-			})
-		}
-
-		// Bagian link
-		linkText := text[linkTextStart:linkTextEnd]
-		url := strings.TrimSpace(text[urlStart:urlEnd])
-
-		objects = append(objects, TextObject{
-			Type: "text",
-			Text: &Text{
-				Content: linkText,
-				Link: &Link{
-					URL: url,
-				},
-			},
-			Annotations: &Annotations{
-				Bold:   false,
-				Italic: false,
-				Code:   false,
-				Color:  "default",
-			},
-		})
+type Icon struct {
+	Type  string `json:"type"`
+	Emoji string `json:"emoji,omitempty"`
+}
 
-		lastEnd = end
+// DividerBlock has no content; Notion still expects an empty object
+type DividerBlock struct{}
+
+// RichText returns the rich text of the block's content, whatever its type
+func (b Block) RichText() []TextObject {
+	switch {
+	case b.Paragraph != nil:
+		return b.Paragraph.RichText
+	case b.Heading1 != nil:
+		return b.Heading1.RichText
+	case b.Heading2 != nil:
+		return b.Heading2.RichText
+	case b.Heading3 != nil:
+		return b.Heading3.RichText
+	case b.BulletedListItem != nil:
+		return b.BulletedListItem.RichText
+	case b.NumberedListItem != nil:
+		return b.NumberedListItem.RichText
+	case b.ToDo != nil:
+		return b.ToDo.RichText
+	case b.Quote != nil:
+		return b.Quote.RichText
+	case b.Toggle != nil:
+		return b.Toggle.RichText
+	case b.Code != nil:
+		return b.Code.RichText
+	case b.Callout != nil:
+		return b.Callout.RichText
 	}
+	return nil
+}
 
-	// Setelah link terakhir
-	if lastEnd < len(text) {
-		after := text[lastEnd:]
-		objects = append(objects, TextObject{
-			Type: "text",
-			Text: &Text{
-				Content: after,
-			},
-			Annotations: &Annotations{
-				Bold:   false,
-				Italic: false,
-				Code:   false,
-				Color:  "default",
-			},
-		})
+// Children returns the nested blocks of the block, whatever its type
+func (b Block) Children() []Block {
+	switch {
+	case b.Paragraph != nil:
+		return b.Paragraph.Children
+	case b.Heading1 != nil:
+		return b.Heading1.Children
+	case b.Heading2 != nil:
+		return b.Heading2.Children
+	case b.Heading3 != nil:
+		return b.Heading3.Children
+	case b.BulletedListItem != nil:
+		return b.BulletedListItem.Children
+	case b.NumberedListItem != nil:
+		return b.NumberedListItem.Children
+	case b.ToDo != nil:
+		return b.ToDo.Children
+	case b.Quote != nil:
+		return b.Quote.Children
+	case b.Toggle != nil:
+		return b.Toggle.Children
+	case b.Callout != nil:
+		return b.Callout.Children
 	}
+	return nil
+}
 
-	return objects
-}
-
-// parseInlineFormatting handles **bold**, *italic*, `code`
-func parseInlineFormatting(text string) []TextObject {
-	// Reuse your existing logic from ParseTextObjects (without link support)
-	// Or keep the old ParseTextObjects but without link handling
-	// We’ll extract just bold/italic/code
-	return []TextObject{
-		{
-			Type: "text",
-			Text: &Text{Content: text},
-			Annotations: &Annotations{
-				Bold:   strings.HasPrefix(text, "**") && strings.HasSuffix(text, "**"),
-				Italic: strings.HasPrefix(text, "*") && strings.HasSuffix(text, "*"),
-				Code:   strings.HasPrefix(text, "`") && strings.HasSuffix(text, "`"),
-				Color:  "default",
-			},
-		},
-	}
-	// For full version, keep your old formatting parser (we simplified here)
-}
-
-// plainTextObject creates a TextObject with default annotations
-func plainTextObject(content string) TextObject {
-	return TextObject{
-		Type: "text",
-		Text: &Text{Content: content},
-		Annotations: &Annotations{
-			Bold:   false,
-			Italic: false,
-			Code:   false,
-			Color:  "default",
-		},
+// setChildren attaches nested blocks to the block's content. Children of
+// block types this package doesn't model are dropped.
+func (b *Block) setChildren(children []Block) {
+	switch {
+	case b.Paragraph != nil:
+		b.Paragraph.Children = children
+	case b.Heading1 != nil:
+		b.Heading1.Children = children
+	case b.Heading2 != nil:
+		b.Heading2.Children = children
+	case b.Heading3 != nil:
+		b.Heading3.Children = children
+	case b.BulletedListItem != nil:
+		b.BulletedListItem.Children = children
+	case b.NumberedListItem != nil:
+		b.NumberedListItem.Children = children
+	case b.ToDo != nil:
+		b.ToDo.Children = children
+	case b.Quote != nil:
+		b.Quote.Children = children
+	case b.Toggle != nil:
+		b.Toggle.Children = children
+	case b.Callout != nil:
+		b.Callout.Children = children
 	}
 }
 
-// indexNotEscaped finds the first unescaped occurrence of substr in s
-func indexNotEscaped(s, substr string) int {
-	i := strings.Index(s, substr)
-	for i > 0 && s[i-1] == '\\' {
-		rest := s[i+len(substr):]
-		next := strings.Index(rest, substr)
-		if next == -1 {
-			return -1
-		}
-		i += next + len(substr) + i + len(substr) - i // Perbaikan logika skip
-		// Lebih aman: kita ubah jadi loop sederhana
+// withChildren returns a copy of the block with its nested blocks
+// replaced, leaving the block itself untouched
+func (b Block) withChildren(children []Block) Block {
+	switch {
+	case b.Paragraph != nil:
+		content := *b.Paragraph
+		b.Paragraph = &content
+	case b.Heading1 != nil:
+		content := *b.Heading1
+		b.Heading1 = &content
+	case b.Heading2 != nil:
+		content := *b.Heading2
+		b.Heading2 = &content
+	case b.Heading3 != nil:
+		content := *b.Heading3
+		b.Heading3 = &content
+	case b.BulletedListItem != nil:
+		content := *b.BulletedListItem
+		b.BulletedListItem = &content
+	case b.NumberedListItem != nil:
+		content := *b.NumberedListItem
+		b.NumberedListItem = &content
+	case b.ToDo != nil:
+		content := *b.ToDo
+		b.ToDo = &content
+	case b.Quote != nil:
+		content := *b.Quote
+		b.Quote = &content
+	case b.Toggle != nil:
+		content := *b.Toggle
+		b.Toggle = &content
+	case b.Callout != nil:
+		content := *b.Callout
+		b.Callout = &content
 	}
-	return i
+
+	b.setChildren(children)
+	return b
 }
 
-// Helper functions for creating blocks
+// Helper functions for creating blocks from a line of Markdown text
 func NewHeadingBlock(text string, level int) Block {
-	textObjects := ParseTextObjects(text)
-
-	switch level {
-	case 1:
-		return Block{
-			Type: "heading_1",
-			Heading1: &HeadingBlock{
-				RichText: textObjects,
-			},
-		}
-	case 2:
-		return Block{
-			Type: "heading_2",
-			Heading2: &HeadingBlock{
-				RichText: textObjects,
-			},
-		}
-	case 3:
-		return Block{
-			Type: "heading_3",
-			Heading3: &HeadingBlock{
-				RichText: textObjects,
-			},
-		}
-	default:
+	if level < 1 || level > 3 {
 		return NewParagraphBlock(text)
 	}
+	return newHeading(richText(markdown.ParseInline(text)), level)
 }
 
 func NewListItemBlock(text string) Block {
 	return Block{
 		Type: "bulleted_list_item",
 		BulletedListItem: &ListItemBlock{
-			RichText: ParseTextObjects(text),
+			RichText: richText(markdown.ParseInline(text)),
 		},
 	}
 }
@@ -299,7 +327,7 @@ func NewNumberedListItemBlock(text string) Block {
 	return Block{
 		Type: "numbered_list_item",
 		NumberedListItem: &ListItemBlock{
-			RichText: ParseTextObjects(text),
+			RichText: richText(markdown.ParseInline(text)),
 		},
 	}
 }
@@ -308,7 +336,7 @@ func NewParagraphBlock(text string) Block {
 	return Block{
 		Type: "paragraph",
 		Paragraph: &ParagraphBlock{
-			RichText: ParseTextObjects(text),
+			RichText: richText(markdown.ParseInline(text)),
 		},
 	}
 }
@@ -324,7 +352,7 @@ func NewDateProperty(date string) Property {
 func NewRichTextProperty(text string) Property {
 	return Property{
 		Type:     "rich_text",
-		RichText: ParseTextObjects(text),
+		RichText: richText(markdown.ParseInline(text)),
 	}
 }
 
@@ -348,6 +376,15 @@ func NewPlainRichTextProperty(text string) Property {
 	}
 }
 
+// NewTitleProperty sets the page title. Its text is taken as it is, not
+// as Markdown.
+func NewTitleProperty(text string) Property {
+	return Property{
+		Type:  "title",
+		Title: richText([]markdown.Span{{Text: text}}),
+	}
+}
+
 func NewNumberProperty(num float64) Property {
 	return Property{
 		Type:   "number",
@@ -363,65 +400,3 @@ func NewSelectProperty(value string) Property {
 		},
 	}
 }
-
-// MarkdownToBlocks converts simple Markdown to Notion blocks
-func MarkdownToBlocks(markdown string) []Block {
-	lines := strings.Split(markdown, "\n")
-	blocks := []Block{}
-	i := 0
-
-	for i < len(lines) {
-		line := strings.TrimSpace(lines[i])
-		if line == "" {
-			i++
-			continue
-		}
-
-		switch {
-		case strings.HasPrefix(line, "# "):
-			blocks = append(blocks, NewHeadingBlock(line[2:], 1))
-		case strings.HasPrefix(line, "## "):
-			blocks = append(blocks, NewHeadingBlock(line[3:], 2))
-		case strings.HasPrefix(line, "### "):
-			blocks = append(blocks, NewHeadingBlock(line[4:], 3))
-		case strings.HasPrefix(line, "- "):
-			blocks = append(blocks, NewListItemBlock(line[2:]))
-		case strings.HasPrefix(line, "1. "):
-			blocks = append(blocks, NewNumberedListItemBlock(strings.TrimPrefix(line, "1. ")))
-		case strings.HasPrefix(line, "```"):
-			// TODO: handle code block
-		default:
-			blocks = append(blocks, NewParagraphBlock(line))
-		}
-		i++
-	}
-
-	return blocks
-}
-
-// splitParagraphs splits text into paragraphs (separated by blank lines)
-func splitParagraphs(text string) []string {
-	lines := strings.Split(text, "\n")
-	var paragraphs []string
-	var current string
-
-	for _, line := range lines {
-		if strings.TrimSpace(line) == "" {
-			if current != "" {
-				paragraphs = append(paragraphs, strings.TrimSpace(current))
-				current = ""
-			}
-		} else {
-			if current != "" {
-				current += "\n"
-			}
-			current += line
-		}
-	}
-
-	if current != "" {
-		paragraphs = append(paragraphs, strings.TrimSpace(current))
-	}
-
-	return paragraphs
-}
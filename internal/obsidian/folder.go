@@ -0,0 +1,346 @@
+// Package obsidian syncs entries with a folder of daily notes, such as an
+// Obsidian vault, one Markdown file per date.
+package obsidian
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ahmaruff/hfl/internal/config"
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+// Folder is a sync backend that keeps each entry in a note of its own.
+// A note's ID is its path relative to the vault, with forward slashes.
+type Folder struct {
+	vault       string
+	pattern     string
+	frontmatter bool
+
+	// match recognises note paths; fields lists the placeholder each of
+	// its groups captures
+	match  *regexp.Regexp
+	fields []string
+}
+
+func NewFolder(cfg config.ObsidianConfig) *Folder {
+	folder := &Folder{
+		vault:       cfg.Vault,
+		pattern:     cfg.GetPattern(),
+		frontmatter: cfg.GetFrontmatter(),
+	}
+	folder.match, folder.fields = compile(folder.pattern)
+	return folder
+}
+
+// placeholderPatterns are what each placeholder of a note pattern matches
+var placeholderPatterns = map[string]string{
+	"{date}":  `(\d{4}-\d{2}-\d{2})`,
+	"{year}":  `(\d{4})`,
+	"{month}": `(\d{2})`,
+	"{day}":   `(\d{2})`,
+}
+
+// compile turns a note pattern into a regexp matching the notes it names
+func compile(pattern string) (*regexp.Regexp, []string) {
+	placeholders := regexp.MustCompile(`\{(date|year|month|day)\}`)
+
+	var expr strings.Builder
+	var fields []string
+	last := 0
+	for _, loc := range placeholders.FindAllStringIndex(pattern, -1) {
+		placeholder := pattern[loc[0]:loc[1]]
+		expr.WriteString(regexp.QuoteMeta(pattern[last:loc[0]]))
+		expr.WriteString(placeholderPatterns[placeholder])
+		fields = append(fields, placeholder)
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(pattern[last:]))
+
+	return regexp.MustCompile("^" + expr.String() + "$"), fields
+}
+
+// Name implements hflsync.Backend
+func (f *Folder) Name() string {
+	return "obsidian"
+}
+
+// List returns every note whose path matches the pattern
+func (f *Folder) List() ([]hflsync.Document, error) {
+	var documents []hflsync.Document
+
+	err := filepath.WalkDir(f.vault, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skip .obsidian, .trash and other hidden folders
+		if entry.IsDir() && path != f.vault && strings.HasPrefix(entry.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(f.vault, path)
+		if err != nil {
+			return err
+		}
+		id := filepath.ToSlash(rel)
+
+		date := f.dateOf(id)
+		if date == "" {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		documents = append(documents, hflsync.Document{ID: id, Date: date, EditedAt: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault %s: %w", f.vault, err)
+	}
+
+	return documents, nil
+}
+
+// Changes lists the whole vault: reading a folder is cheap
+func (f *Folder) Changes(cursor string) (hflsync.Changes, error) {
+	documents, err := f.List()
+	if err != nil {
+		return hflsync.Changes{}, err
+	}
+	return hflsync.Changes{Documents: documents, Complete: true}, nil
+}
+
+// Get reads a note. Its frontmatter isn't part of the body.
+func (f *Folder) Get(id string) (hflsync.Document, error) {
+	data, err := os.ReadFile(f.file(id))
+	if os.IsNotExist(err) {
+		return hflsync.Document{}, fmt.Errorf("%w: %s", hflsync.ErrNotFound, id)
+	}
+	if err != nil {
+		return hflsync.Document{}, fmt.Errorf("failed to read note %s: %w", id, err)
+	}
+
+	_, body := splitNote(string(data))
+	return f.document(id, body)
+}
+
+// Create writes the note of a new entry. An existing note is never
+// overwritten.
+func (f *Folder) Create(entry parser.Entry) (hflsync.Document, error) {
+	id := f.path(entry.Date)
+	file := f.file(id)
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return hflsync.Document{}, fmt.Errorf("failed to create folder for %s: %w", id, err)
+	}
+
+	front := ""
+	if f.frontmatter {
+		front = newFrontmatter(entry.Date)
+	}
+
+	out, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return hflsync.Document{}, fmt.Errorf("failed to create note %s: %w", id, err)
+	}
+	if _, err := out.WriteString(joinNote(front, entry.Body)); err != nil {
+		out.Close()
+		return hflsync.Document{}, fmt.Errorf("failed to write note %s: %w", id, err)
+	}
+	if err := out.Close(); err != nil {
+		return hflsync.Document{}, fmt.Errorf("failed to write note %s: %w", id, err)
+	}
+
+	return f.document(id, normalize(entry.Body))
+}
+
+// Update replaces the body of a note, keeping its frontmatter
+func (f *Folder) Update(id string, entry parser.Entry, previous state.EntryState) (hflsync.Document, error) {
+	file := f.file(id)
+
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return hflsync.Document{}, fmt.Errorf("%w: %s", hflsync.ErrNotFound, id)
+	}
+	if err != nil {
+		return hflsync.Document{}, fmt.Errorf("failed to read note %s: %w", id, err)
+	}
+
+	front, _ := splitNote(string(data))
+	if front == "" && f.frontmatter {
+		front = newFrontmatter(entry.Date)
+	}
+
+	// Written to a temporary file first, so a note open in Obsidian is
+	// never seen half written
+	tmp := file + ".hfl-tmp"
+	if err := os.WriteFile(tmp, []byte(joinNote(front, entry.Body)), 0644); err != nil {
+		return hflsync.Document{}, fmt.Errorf("failed to write note %s: %w", id, err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return hflsync.Document{}, fmt.Errorf("failed to write note %s: %w", id, err)
+	}
+
+	return f.document(id, normalize(entry.Body))
+}
+
+// Delete removes a note
+func (f *Folder) Delete(id string) error {
+	err := os.Remove(f.file(id))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", hflsync.ErrNotFound, id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete note %s: %w", id, err)
+	}
+	return nil
+}
+
+// Normalize returns body as it reads back from a note
+func (f *Folder) Normalize(body string) string {
+	return normalize(body)
+}
+
+// path returns the ID of the note for date
+func (f *Folder) path(date string) string {
+	return strings.NewReplacer(
+		"{date}", date,
+		"{year}", date[:4],
+		"{month}", date[5:7],
+		"{day}", date[8:],
+	).Replace(f.pattern)
+}
+
+// dateOf returns the date of the note at id, or "" when id isn't a note
+// the pattern names
+func (f *Folder) dateOf(id string) string {
+	groups := f.match.FindStringSubmatch(id)
+	if groups == nil {
+		return ""
+	}
+
+	parts := make(map[string]string)
+	for i, field := range f.fields {
+		parts[field] = groups[i+1]
+	}
+
+	date := parts["{date}"]
+	if date == "" {
+		date = parts["{year}"] + "-" + parts["{month}"] + "-" + parts["{day}"]
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return ""
+	}
+
+	// Placeholders repeated in the pattern must agree
+	if f.path(date) != id {
+		return ""
+	}
+	return date
+}
+
+// file returns the path of a note on disk
+func (f *Folder) file(id string) string {
+	return filepath.Join(f.vault, filepath.FromSlash(id))
+}
+
+// document describes a note with the given body
+func (f *Folder) document(id, body string) (hflsync.Document, error) {
+	info, err := os.Stat(f.file(id))
+	if err != nil {
+		return hflsync.Document{}, fmt.Errorf("failed to stat note %s: %w", id, err)
+	}
+
+	return hflsync.Document{
+		ID:       id,
+		Date:     f.dateOf(id),
+		Body:     body,
+		EditedAt: info.ModTime(),
+	}, nil
+}
+
+// newFrontmatter returns the frontmatter of a new note
+func newFrontmatter(date string) string {
+	return "---\ndate: " + date + "\n---\n"
+}
+
+// splitNote separates a note's frontmatter, delimiters included, from its
+// body. Entries can start with a divider too, so a block between `---`
+// lines only counts as frontmatter when it reads as YAML properties.
+func splitNote(text string) (string, string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[0] != "---\n" {
+		return "", normalize(text)
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\n") == "---" {
+			if !isProperties(lines[1:i]) {
+				return "", normalize(text)
+			}
+			front := strings.Join(lines[:i+1], "")
+			if !strings.HasSuffix(front, "\n") {
+				front += "\n"
+			}
+			return front, normalize(strings.Join(lines[i+1:], ""))
+		}
+	}
+
+	// Never closed, so it's not frontmatter
+	return "", normalize(text)
+}
+
+// propertyLine matches a YAML key, e.g. "tags:" or "date: 2025-08-16"
+var propertyLine = regexp.MustCompile(`^[\w-]+:(\s|$)`)
+
+// isProperties reports whether lines read as YAML properties: each one a
+// key, a list item or an indented continuation of the one before
+func isProperties(lines []string) bool {
+	keys := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.TrimSpace(line) == "":
+		case propertyLine.MatchString(line):
+			keys++
+		case keys > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "- ")):
+		default:
+			return false
+		}
+	}
+	return keys > 0
+}
+
+// joinNote returns the text of a note, with a blank line between
+// frontmatter and body
+func joinNote(front, body string) string {
+	body = normalize(body)
+	if front == "" {
+		return body + "\n"
+	}
+	return front + "\n" + body + "\n"
+}
+
+// normalize trims the blank lines around a body, the way the parser
+// trims them in hfl.md
+func normalize(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	return strings.TrimRight(strings.TrimLeft(body, "\n"), "\n")
+}
@@ -0,0 +1,235 @@
+package obsidian
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ahmaruff/hfl/internal/config"
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+	hflsync "github.com/ahmaruff/hfl/internal/sync"
+)
+
+// chdirTemp runs the test in an empty directory so state files stay isolated
+func chdirTemp(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	t.Cleanup(func() { os.Chdir(originalDir) })
+}
+
+func newFolder(t *testing.T, pattern string, frontmatter bool) *Folder {
+	return NewFolder(config.ObsidianConfig{Vault: t.TempDir(), Pattern: pattern, Frontmatter: &frontmatter})
+}
+
+func newState() *state.State {
+	return &state.State{Entries: make(map[string]state.EntryState)}
+}
+
+// editNote rewrites a note as if it was edited in Obsidian, a minute later
+func editNote(t *testing.T, folder *Folder, id, text string) {
+	file := folder.file(id)
+	if err := os.WriteFile(file, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(file, later, later)
+}
+
+func readNote(t *testing.T, folder *Folder, id string) string {
+	data, err := os.ReadFile(folder.file(id))
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", id, err)
+	}
+	return string(data)
+}
+
+func TestFolder_PushWritesNotes(t *testing.T) {
+	chdirTemp(t)
+	folder := newFolder(t, "Daily/{date}.md", true)
+	engine := hflsync.NewEngine(folder, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-16", Body: "Newer entry.\n\nSecond paragraph."},
+		{Date: "2025-08-15", Body: "Older entry."},
+	}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if id := syncState.Entries["2025-08-16"].RemoteID; id != "Daily/2025-08-16.md" {
+		t.Fatalf("Expected Daily/2025-08-16.md, got %q", id)
+	}
+
+	expected := "---\ndate: 2025-08-16\n---\n\nNewer entry.\n\nSecond paragraph.\n"
+	if text := readNote(t, folder, "Daily/2025-08-16.md"); text != expected {
+		t.Errorf("Expected %q, got %q", expected, text)
+	}
+
+	// An untouched vault has nothing to pull
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	for _, item := range engine.Report() {
+		if item.Action == hflsync.ActionPulled {
+			t.Errorf("Expected no pull after a push, got %+v", item)
+		}
+	}
+}
+
+func TestFolder_PullsEditsAndKeepsFrontmatter(t *testing.T) {
+	chdirTemp(t)
+	folder := newFolder(t, "{date}.md", false)
+	engine := hflsync.NewEngine(folder, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Written in hfl."}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Obsidian adds tags in frontmatter and edits the body, with CRLF endings
+	editNote(t, folder, "2025-08-16.md", "---\r\ntags: [daily]\r\n---\r\nEdited in Obsidian.\r\n")
+
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if body := journal.Entries[0].Body; body != "Edited in Obsidian." {
+		t.Fatalf("Expected the edited body without frontmatter, got %q", body)
+	}
+
+	journal.Entries[0].Body = "Edited in hfl again."
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	expected := "---\ntags: [daily]\n---\n\nEdited in hfl again.\n"
+	if text := readNote(t, folder, "2025-08-16.md"); text != expected {
+		t.Errorf("Expected frontmatter to be kept, got %q", text)
+	}
+}
+
+func TestFolder_ConflictMergesBothSides(t *testing.T) {
+	chdirTemp(t)
+	folder := newFolder(t, "{date}.md", false)
+	engine := hflsync.NewEngine(folder, hflsync.Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "One.\n\nTwo.\n\nThree."}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	editNote(t, folder, "2025-08-16.md", "One in Obsidian.\n\nTwo.\n\nThree.\n")
+	journal.Entries[0].Body = "One.\n\nTwo.\n\nThree in hfl."
+
+	conflicts, err := engine.DetectConflicts(journal, syncState)
+	if err != nil {
+		t.Fatalf("DetectConflicts failed: %v", err)
+	}
+	if _, err := engine.MergeConflicts(journal, syncState, conflicts); err != nil {
+		t.Fatalf("MergeConflicts failed: %v", err)
+	}
+
+	if expected := "One in Obsidian.\n\nTwo.\n\nThree in hfl."; journal.Entries[0].Body != expected {
+		t.Errorf("Expected %q, got %q", expected, journal.Entries[0].Body)
+	}
+}
+
+func TestFolder_BodyStartingWithDivider(t *testing.T) {
+	chdirTemp(t)
+	folder := newFolder(t, "{date}.md", false)
+	engine := hflsync.NewEngine(folder, hflsync.Options{})
+
+	body := "---\nMorning run.\n---\nEvening read."
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: body}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	document, err := folder.Get("2025-08-16.md")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if document.Body != body {
+		t.Errorf("Expected the dividers to stay in the body, got %q", document.Body)
+	}
+
+	journal.Entries[0].Body = body + "\n\nLater."
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if text := readNote(t, folder, "2025-08-16.md"); text != body+"\n\nLater.\n" {
+		t.Errorf("Expected the note to hold only the body, got %q", text)
+	}
+}
+
+func TestFolder_ListMatchesPattern(t *testing.T) {
+	folder := newFolder(t, "{year}/{month}/{year}-{month}-{day}.md", false)
+
+	for _, id := range []string{
+		"2025/08/2025-08-16.md",
+		"2025/08/2024-08-16.md", // year doesn't agree
+		"2025/13/2025-13-01.md", // no such month
+		"2025/08/notes.md",
+		".trash/2025/08/2025-08-15.md",
+		"Inbox.md",
+	} {
+		file := folder.file(id)
+		os.MkdirAll(filepath.Dir(file), 0755)
+		os.WriteFile(file, []byte("Body.\n"), 0644)
+	}
+
+	documents, err := folder.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	if len(documents) != 1 || documents[0].ID != "2025/08/2025-08-16.md" || documents[0].Date != "2025-08-16" {
+		t.Errorf("Expected only 2025/08/2025-08-16.md, got %+v", documents)
+	}
+}
+
+func TestFolder_NotesWrittenInObsidian(t *testing.T) {
+	chdirTemp(t)
+	folder := newFolder(t, "Daily/{date}.md", false)
+	engine := hflsync.NewEngine(folder, hflsync.Options{})
+
+	os.MkdirAll(filepath.Join(folder.vault, "Daily"), 0755)
+	editNote(t, folder, "Daily/2025-08-14.md", "Only in Obsidian.\n")
+	editNote(t, folder, "Daily/2025-08-15.md", "Written twice.\n")
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-15", Body: "Written twice."}}}
+	syncState := newState()
+
+	// A note that already exists is linked, not overwritten
+	if err := engine.AdoptExisting(journal, syncState); err != nil {
+		t.Fatalf("AdoptExisting failed: %v", err)
+	}
+	if entry := syncState.Entries["2025-08-15"]; entry.RemoteID != "Daily/2025-08-15.md" || entry.Conflict {
+		t.Errorf("Expected a clean link, got %+v", entry)
+	}
+
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if len(journal.Entries) != 2 || journal.Entries[1].Body != "Only in Obsidian." {
+		t.Errorf("Expected the new note to be pulled, got %+v", journal.Entries)
+	}
+}
+
+func TestFolder_DeleteMissingNote(t *testing.T) {
+	folder := newFolder(t, "{date}.md", false)
+
+	if _, err := folder.Get("2025-08-16.md"); !errors.Is(err, hflsync.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := folder.Delete("2025-08-16.md"); !errors.Is(err, hflsync.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
@@ -17,6 +18,15 @@ func ParseFile(filename string) (*Journal, []string, error) {
 	}
 	defer file.Close()
 
+	journal, warnings, err := Parse(file)
+	if err != nil {
+		return nil, warnings, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	return journal, warnings, nil
+}
+
+// Parse reads a journal in hfl.md format, e.g. an older version of the file
+func Parse(r io.Reader) (*Journal, []string, error) {
 	var journal Journal
 	var warnings []string
 	seenDates := make(map[string]int)
@@ -28,7 +38,7 @@ func ParseFile(filename string) (*Journal, []string, error) {
 	var headerLines []string
 	foundFirstEntry := false
 
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 
 	lineNum := 0
 	for scanner.Scan() {
@@ -114,7 +124,7 @@ func ParseFile(filename string) (*Journal, []string, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, warnings, fmt.Errorf("error reading file %s: %w", filename, err)
+		return nil, warnings, err
 	}
 
 	return &journal, warnings, nil
@@ -0,0 +1,62 @@
+package state
+
+import "time"
+
+// Operations a queued change can carry
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// QueuedOp is a change that couldn't reach the backend, e.g. while offline.
+// It is kept until a later sync pushes it.
+type QueuedOp struct {
+	Op       string `json:"op"`
+	Date     string `json:"date"`
+	Hash     string `json:"hash,omitempty"` // body to push; empty for deletes
+	QueuedAt string `json:"queued_at"`
+	Error    string `json:"error,omitempty"` // why it couldn't be pushed
+}
+
+// Enqueue records a change to the entry of date. Each date is queued once:
+// a later change replaces the earlier one but keeps its place in the
+// queue, so repeated edits are pushed as one. An entry that was never
+// created stays a creation however often it is edited.
+func (s *State) Enqueue(op, date, body string, reason error) {
+	queued := QueuedOp{
+		Op:       op,
+		Date:     date,
+		QueuedAt: time.Now().Format(time.RFC3339),
+	}
+	if op != OpDelete {
+		queued.Hash = calculateHash(body)
+	}
+	if reason != nil {
+		queued.Error = reason.Error()
+	}
+
+	for i, existing := range s.Queue {
+		if existing.Date != date {
+			continue
+		}
+		if existing.Op == OpCreate && op == OpUpdate {
+			queued.Op = OpCreate
+		}
+		queued.QueuedAt = existing.QueuedAt
+		s.Queue[i] = queued
+		return
+	}
+
+	s.Queue = append(s.Queue, queued)
+}
+
+// Dequeue drops the queued change of date, if there is one
+func (s *State) Dequeue(date string) {
+	for i, queued := range s.Queue {
+		if queued.Date == date {
+			s.Queue = append(s.Queue[:i], s.Queue[i+1:]...)
+			return
+		}
+	}
+}
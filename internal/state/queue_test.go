@@ -0,0 +1,62 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestEnqueue_CoalescesByDate(t *testing.T) {
+	state := &State{Entries: make(map[string]EntryState)}
+	offline := errors.New("offline")
+
+	state.Enqueue(OpCreate, "2025-08-15", "New.", offline)
+	state.Enqueue(OpUpdate, "2025-08-16", "First edit.", offline)
+	state.Enqueue(OpUpdate, "2025-08-15", "New, edited.", offline)
+	state.Enqueue(OpUpdate, "2025-08-16", "Second edit.", offline)
+
+	if len(state.Queue) != 2 {
+		t.Fatalf("Expected one change per date, got %+v", state.Queue)
+	}
+
+	// A creation edited before it was pushed is still a creation
+	first := state.Queue[0]
+	if first.Date != "2025-08-15" || first.Op != OpCreate || first.Hash != HashContent("New, edited.") {
+		t.Errorf("Expected the latest body of 2025-08-15 as a creation, got %+v", first)
+	}
+
+	second := state.Queue[1]
+	if second.Date != "2025-08-16" || second.Hash != HashContent("Second edit.") || second.Error != "offline" {
+		t.Errorf("Expected the latest body of 2025-08-16, got %+v", second)
+	}
+
+	state.Enqueue(OpDelete, "2025-08-16", "", offline)
+	if state.Queue[1].Op != OpDelete || state.Queue[1].Hash != "" {
+		t.Errorf("Expected the delete to replace the edit, got %+v", state.Queue[1])
+	}
+
+	state.Dequeue("2025-08-15")
+	if len(state.Queue) != 1 || state.Queue[0].Date != "2025-08-16" {
+		t.Errorf("Expected only 2025-08-16 left, got %+v", state.Queue)
+	}
+}
+
+func TestSave_KeepsQueue(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(originalDir)
+
+	state, _ := Load("notion")
+	state.Enqueue(OpUpdate, "2025-08-16", "Edited offline.", errors.New("offline"))
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := Load("notion")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Queue) != 1 || loaded.Queue[0].Hash != HashContent("Edited offline.") {
+		t.Errorf("Expected the queue to survive a reload, got %+v", loaded.Queue)
+	}
+}
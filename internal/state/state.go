@@ -6,28 +6,94 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
+
+	"github.com/ahmaruff/hfl/internal/parser"
 )
 
 type EntryState struct {
-	NotionID       string `json:"notion_id,omitempty"`
+	RemoteID       string `json:"remote_id,omitempty"` // the entry's document in the backend
 	Hash           string `json:"hash,omitempty"`
+	RemoteHash     string `json:"remote_hash,omitempty"`
 	LastRemoteEdit string `json:"last_remote_edit,omitempty"`
+	RemoteSeenAt   string `json:"remote_seen_at,omitempty"` // when LastRemoteEdit was recorded
 	LastLocalSync  string `json:"last_local_sync"`
+	Conflict       bool   `json:"conflict,omitempty"`
+	Resolution     string `json:"resolution,omitempty"`
+	DeletedAt      string `json:"deleted_at,omitempty"`     // tombstone: removed from hfl.md
+	Unlinked       bool   `json:"unlinked,omitempty"`       // kept locally after its document was deleted
+	PendingCreate  string `json:"pending_create,omitempty"` // document creation started but not confirmed
+	Title          string `json:"title,omitempty"`          // page title last written by hfl
+}
+
+// Resolutions recorded for conflicted entries
+const (
+	ResolutionLocal  = "local"  // push the local body over the backend
+	ResolutionRemote = "remote" // pull the remote body over hfl.md
+	ResolutionSkip   = "skip"   // leave the conflict for a later session
+)
+
+// UnmarshalJSON also reads notion_id, the name of RemoteID before there
+// were other backends
+func (e *EntryState) UnmarshalJSON(data []byte) error {
+	type plain EntryState
+	var decoded struct {
+		plain
+		NotionID string `json:"notion_id"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*e = EntryState(decoded.plain)
+	if e.RemoteID == "" {
+		e.RemoteID = decoded.NotionID
+	}
+	return nil
 }
 
 type State struct {
 	LastSynced string                `json:"last_synced,omitempty"`
+	Cursor     string                `json:"cursor,omitempty"` // backend change cursor of the last pull
+	Run        *Run                  `json:"run,omitempty"`
+	Queue      []QueuedOp            `json:"queue,omitempty"` // changes waiting to be pushed
 	Entries    map[string]EntryState `json:"entries"`
+
+	// backend is the name of the backend the state belongs to
+	backend string
+	// bases holds last-synced bodies not yet written to the base directory
+	bases map[string]string
+}
+
+// Run records a sync in progress. It is cleared when the sync finishes, so
+// one still present on load was interrupted.
+type Run struct {
+	Mode      string `json:"mode"` // "push", "pull" or "sync"
+	StartedAt string `json:"started_at"`
 }
 
-func Load() (*State, error) {
-	statePath := ".hfl/state.json"
+// legacyBackend keeps the paths used before state was kept per backend
+const legacyBackend = "notion"
+
+// dir returns the directory of a backend's state
+func dir(backend string) string {
+	if backend == "" || backend == legacyBackend {
+		return ".hfl"
+	}
+	return filepath.Join(".hfl", backend)
+}
+
+// Load reads the sync state of a backend. Each backend keeps its own,
+// since an entry has a different document and sync history in each.
+func Load(backend string) (*State, error) {
+	statePath := filepath.Join(dir(backend), "state.json")
 
 	// Return empty state if file doesn't exist
 	if _, err := os.Stat(statePath); os.IsNotExist(err) {
 		return &State{
 			Entries: make(map[string]EntryState),
+			backend: backend,
 		}, nil
 	}
 
@@ -36,7 +102,7 @@ func Load() (*State, error) {
 		return nil, fmt.Errorf("failed to read state file: %w", err)
 	}
 
-	var state State
+	state := State{backend: backend}
 	if err := json.Unmarshal(data, &state); err != nil {
 		return nil, fmt.Errorf("failed to parse state file: %w", err)
 	}
@@ -50,7 +116,7 @@ func Load() (*State, error) {
 }
 
 func (s *State) Save() error {
-	statePath := ".hfl/state.json"
+	statePath := filepath.Join(dir(s.backend), "state.json")
 
 	// Ensure .hfl directory exists
 	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
@@ -62,13 +128,35 @@ func (s *State) Save() error {
 		return fmt.Errorf("failed to marshal state: %w", err)
 	}
 
-	if err := os.WriteFile(statePath, data, 0644); err != nil {
+	// Write to a temporary file and rename it over the old one, so a crash
+	// mid-write never leaves a truncated state file
+	tmpPath := statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write state file: %w", err)
 	}
+	if err := os.Rename(tmpPath, statePath); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if len(s.bases) > 0 {
+		if err := os.MkdirAll(s.baseDir(), 0755); err != nil {
+			return fmt.Errorf("failed to create base directory: %w", err)
+		}
+
+		for date, body := range s.bases {
+			if err := os.WriteFile(s.basePath(date), []byte(body), 0644); err != nil {
+				return fmt.Errorf("failed to write base for %s: %w", date, err)
+			}
+			delete(s.bases, date)
+		}
+	}
 
 	return nil
 }
 
+// UpdateEntry records body as the last-synced version of the entry, which
+// also settles any conflict on it. The body is kept as the common
+// ancestor for future merges.
 func (s *State) UpdateEntry(date, body string) {
 	hash := calculateHash(body)
 	now := time.Now().Format(time.RFC3339)
@@ -76,8 +164,50 @@ func (s *State) UpdateEntry(date, body string) {
 	entry := s.Entries[date]
 	entry.Hash = hash
 	entry.LastLocalSync = now
+	entry.Conflict = false
+	entry.Resolution = ""
 
 	s.Entries[date] = entry
+
+	if s.bases == nil {
+		s.bases = make(map[string]string)
+	}
+	s.bases[date] = body
+}
+
+// Base returns the body of the entry as it was when last synced. It
+// reports false when no base was recorded or it no longer matches the
+// last-synced hash.
+func (s *State) Base(date string) (string, bool) {
+	entry, exists := s.Entries[date]
+	if !exists {
+		return "", false
+	}
+
+	body, pending := s.bases[date]
+	if !pending {
+		data, err := os.ReadFile(s.basePath(date))
+		if err != nil {
+			return "", false
+		}
+		body = string(data)
+	}
+
+	if calculateHash(body) != entry.Hash {
+		return "", false
+	}
+
+	return body, true
+}
+
+// baseDir keeps the last-synced body of every entry, the common ancestor
+// for three-way merges
+func (s *State) baseDir() string {
+	return filepath.Join(dir(s.backend), "base")
+}
+
+func (s *State) basePath(date string) string {
+	return filepath.Join(s.baseDir(), date+".md")
 }
 
 func (s *State) GetEntry(date string) (EntryState, bool) {
@@ -95,6 +225,11 @@ func (s *State) HasChanged(date, body string) bool {
 	return entry.Hash != currentHash
 }
 
+// HashContent returns the hash used to track entry bodies, local or remote
+func HashContent(content string) string {
+	return calculateHash(content)
+}
+
 func calculateHash(content string) string {
 	hash := sha256.Sum256([]byte(content))
 	return fmt.Sprintf("%x", hash)
@@ -104,8 +239,174 @@ func (s *State) SetLastSynced(timestamp string) {
 	s.LastSynced = timestamp
 }
 
-func (s *State) SetNotionID(date, notionID string) {
+// SetRemoteID links an entry to its document in the backend
+func (s *State) SetRemoteID(date, remoteID string) {
+	entry := s.Entries[date]
+	entry.RemoteID = remoteID
+	s.Entries[date] = entry
+}
+
+// SetTitle records the page title hfl last wrote for an entry
+func (s *State) SetTitle(date, title string) {
+	entry := s.Entries[date]
+	entry.Title = title
+	s.Entries[date] = entry
+}
+
+// SetRemote records the remote last-edited time and content hash seen
+// when an entry was last pushed or pulled
+func (s *State) SetRemote(date, lastRemoteEdit, remoteHash string) {
 	entry := s.Entries[date]
-	entry.NotionID = notionID
+	entry.LastRemoteEdit = lastRemoteEdit
+	entry.RemoteHash = remoteHash
+	entry.RemoteSeenAt = time.Now().UTC().Format(time.RFC3339)
 	s.Entries[date] = entry
 }
+
+// MarkConflict flags an entry as changed on both sides. A resolution
+// already recorded for it is kept, so an interrupted session can resume.
+func (s *State) MarkConflict(date string) {
+	entry := s.Entries[date]
+	entry.Conflict = true
+	s.Entries[date] = entry
+}
+
+// ClearConflict drops the conflict flag and any recorded resolution
+func (s *State) ClearConflict(date string) {
+	entry := s.Entries[date]
+	entry.Conflict = false
+	entry.Resolution = ""
+	s.Entries[date] = entry
+}
+
+// Resolve records how a conflicted entry should be settled
+func (s *State) Resolve(date, resolution string) {
+	entry := s.Entries[date]
+	entry.Resolution = resolution
+	s.Entries[date] = entry
+}
+
+// Conflicts returns the dates flagged as conflicted, oldest first
+func (s *State) Conflicts() []string {
+	var dates []string
+	for date, entry := range s.Entries {
+		if entry.Conflict {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// CanPush reports whether a push may overwrite the entry in the backend
+func (e EntryState) CanPush() bool {
+	return !e.Conflict || e.Resolution == ResolutionLocal
+}
+
+// CanPull reports whether a pull may overwrite the entry in hfl.md
+func (e EntryState) CanPull() bool {
+	return !e.Conflict || e.Resolution == ResolutionRemote
+}
+
+// RecordDeletions tombstones every entry in state whose date is no longer
+// in the journal, and lifts the tombstone of dates that came back. It
+// returns all dates currently tombstoned.
+func (s *State) RecordDeletions(journal *parser.Journal) []string {
+	present := make(map[string]bool, len(journal.Entries))
+	for _, entry := range journal.Entries {
+		present[entry.Date] = true
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for date, entry := range s.Entries {
+		switch {
+		case !present[date] && entry.DeletedAt == "":
+			entry.DeletedAt = now
+			s.Entries[date] = entry
+		case present[date] && entry.DeletedAt != "":
+			entry.DeletedAt = ""
+			s.Entries[date] = entry
+		}
+	}
+
+	return s.Tombstones()
+}
+
+// Tombstones returns the dates deleted locally but not yet removed from
+// the remote side, oldest first
+func (s *State) Tombstones() []string {
+	var dates []string
+	for date, entry := range s.Entries {
+		if entry.DeletedAt != "" {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+	return dates
+}
+
+// RemoveEntry forgets an entry entirely, including its merge base
+func (s *State) RemoveEntry(date string) {
+	delete(s.Entries, date)
+	delete(s.bases, date)
+	os.Remove(s.basePath(date))
+}
+
+// Detach forgets the remote document of an entry, so the next push
+// creates a new one
+func (s *State) Detach(date string) {
+	entry := s.Entries[date]
+	entry.RemoteID = ""
+	entry.RemoteHash = ""
+	entry.LastRemoteEdit = ""
+	entry.Conflict = false
+	entry.Resolution = ""
+	entry.Title = ""
+	s.Entries[date] = entry
+}
+
+// Unlink detaches an entry and keeps it out of later syncs, so it lives
+// only in hfl.md
+func (s *State) Unlink(date string) {
+	s.Detach(date)
+	entry := s.Entries[date]
+	entry.Unlinked = true
+	s.Entries[date] = entry
+}
+
+// BeginRun records that a sync started, replacing any interrupted one
+func (s *State) BeginRun(mode string) {
+	s.Run = &Run{Mode: mode, StartedAt: time.Now().Format(time.RFC3339)}
+}
+
+// EndRun records that the sync in progress finished
+func (s *State) EndRun() {
+	s.Run = nil
+}
+
+// MarkPendingCreate records that a page is about to be created for an
+// entry. If the process dies before the page ID is saved, the marker tells
+// the next sync to look for that page before creating another one.
+func (s *State) MarkPendingCreate(date string) {
+	entry := s.Entries[date]
+	entry.PendingCreate = time.Now().Format(time.RFC3339)
+	s.Entries[date] = entry
+}
+
+// ClearPendingCreate drops the pending creation marker of an entry
+func (s *State) ClearPendingCreate(date string) {
+	entry := s.Entries[date]
+	entry.PendingCreate = ""
+	s.Entries[date] = entry
+}
+
+// Reset forgets every entry and merge base, as if nothing was ever synced
+func (s *State) Reset() {
+	s.LastSynced = ""
+	s.Cursor = ""
+	s.Run = nil
+	s.Queue = nil
+	s.Entries = make(map[string]EntryState)
+	s.bases = nil
+	os.RemoveAll(s.baseDir())
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/ahmaruff/hfl/internal/parser"
 )
 
 func TestLoad_NoStateFile(t *testing.T) {
@@ -14,7 +16,7 @@ func TestLoad_NoStateFile(t *testing.T) {
 	os.Chdir(tempDir)
 	defer os.Chdir(originalDir)
 
-	state, err := Load()
+	state, err := Load("notion")
 	if err != nil {
 		t.Fatalf("Load() should not fail when no state file exists: %v", err)
 	}
@@ -52,7 +54,7 @@ func TestLoad_ExistingStateFile(t *testing.T) {
 		LastSynced: "2025-08-17T10:30:00Z",
 		Entries: map[string]EntryState{
 			"2025-08-16": {
-				NotionID:       "abc123",
+				RemoteID:       "abc123",
 				Hash:           "test-hash",
 				LastRemoteEdit: "2025-08-16T15:30:00Z",
 				LastLocalSync:  "2025-08-16T16:00:00Z",
@@ -67,7 +69,7 @@ func TestLoad_ExistingStateFile(t *testing.T) {
 	}
 
 	// Load the state
-	state, err := Load()
+	state, err := Load("notion")
 	if err != nil {
 		t.Fatalf("Load() failed: %v", err)
 	}
@@ -81,8 +83,8 @@ func TestLoad_ExistingStateFile(t *testing.T) {
 		t.Fatal("Expected entry for 2025-08-16")
 	}
 
-	if entry.NotionID != "abc123" {
-		t.Errorf("Expected notion_id 'abc123', got %q", entry.NotionID)
+	if entry.RemoteID != "abc123" {
+		t.Errorf("Expected remote_id 'abc123', got %q", entry.RemoteID)
 	}
 
 	if entry.Hash != "test-hash" {
@@ -100,7 +102,7 @@ func TestSave(t *testing.T) {
 		LastSynced: "2025-08-17T10:30:00Z",
 		Entries: map[string]EntryState{
 			"2025-08-16": {
-				NotionID:      "abc123",
+				RemoteID:      "abc123",
 				Hash:          "test-hash",
 				LastLocalSync: "2025-08-16T16:00:00Z",
 			},
@@ -179,7 +181,7 @@ func TestGetEntry(t *testing.T) {
 	state := &State{
 		Entries: map[string]EntryState{
 			"2025-08-16": {
-				NotionID: "abc123",
+				RemoteID: "abc123",
 				Hash:     "test-hash",
 			},
 		},
@@ -191,8 +193,8 @@ func TestGetEntry(t *testing.T) {
 		t.Error("Expected entry to exist")
 	}
 
-	if entry.NotionID != "abc123" {
-		t.Errorf("Expected notion_id 'abc123', got %q", entry.NotionID)
+	if entry.RemoteID != "abc123" {
+		t.Errorf("Expected remote_id 'abc123', got %q", entry.RemoteID)
 	}
 
 	// Test non-existing entry
@@ -267,21 +269,21 @@ func TestSetLastSynced(t *testing.T) {
 	}
 }
 
-func TestSetNotionID(t *testing.T) {
+func TestSetRemoteID(t *testing.T) {
 	state := &State{
 		Entries: make(map[string]EntryState),
 	}
 
 	// Set notion ID for non-existing entry
-	state.SetNotionID("2025-08-16", "abc123")
+	state.SetRemoteID("2025-08-16", "abc123")
 
 	entry, exists := state.Entries["2025-08-16"]
 	if !exists {
 		t.Fatal("Expected entry to be created")
 	}
 
-	if entry.NotionID != "abc123" {
-		t.Errorf("Expected notion_id 'abc123', got %q", entry.NotionID)
+	if entry.RemoteID != "abc123" {
+		t.Errorf("Expected remote_id 'abc123', got %q", entry.RemoteID)
 	}
 
 	// Update notion ID for existing entry
@@ -290,11 +292,11 @@ func TestSetNotionID(t *testing.T) {
 		LastLocalSync: "2025-08-16T10:00:00Z",
 	}
 
-	state.SetNotionID("2025-08-16", "xyz789")
+	state.SetRemoteID("2025-08-16", "xyz789")
 
 	updatedEntry := state.Entries["2025-08-16"]
-	if updatedEntry.NotionID != "xyz789" {
-		t.Errorf("Expected updated notion_id 'xyz789', got %q", updatedEntry.NotionID)
+	if updatedEntry.RemoteID != "xyz789" {
+		t.Errorf("Expected updated remote_id 'xyz789', got %q", updatedEntry.RemoteID)
 	}
 
 	// Verify other fields are preserved
@@ -320,7 +322,7 @@ func TestLoad_InvalidJSON(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = Load()
+	_, err = Load("notion")
 	if err == nil {
 		t.Error("Expected error when loading invalid JSON state")
 	}
@@ -352,3 +354,304 @@ func TestSave_CreateDirectory(t *testing.T) {
 		t.Error("Expected state.json file to be created")
 	}
 }
+
+func TestSetRemote(t *testing.T) {
+	state := &State{
+		Entries: make(map[string]EntryState),
+	}
+
+	state.UpdateEntry("2025-08-16", "Local content")
+	state.SetRemoteID("2025-08-16", "abc123")
+	state.SetRemote("2025-08-16", "2025-08-16T10:00:00Z", HashContent("Remote content"))
+
+	entry := state.Entries["2025-08-16"]
+	if entry.LastRemoteEdit != "2025-08-16T10:00:00Z" {
+		t.Errorf("Expected last_remote_edit to be set, got %q", entry.LastRemoteEdit)
+	}
+
+	if entry.RemoteHash != calculateHash("Remote content") {
+		t.Error("Expected remote_hash to match remote content")
+	}
+
+	// Verify other fields are preserved
+	if entry.RemoteID != "abc123" || entry.Hash != calculateHash("Local content") {
+		t.Error("Expected remote_id and local hash to be preserved")
+	}
+}
+
+func TestBase(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalDir)
+
+	state := &State{
+		Entries: make(map[string]EntryState),
+	}
+
+	// No base before the entry is synced
+	if _, ok := state.Base("2025-08-16"); ok {
+		t.Error("Expected no base for unknown entry")
+	}
+
+	state.UpdateEntry("2025-08-16", "Synced body")
+
+	if base, ok := state.Base("2025-08-16"); !ok || base != "Synced body" {
+		t.Errorf("Expected pending base 'Synced body', got %q (%v)", base, ok)
+	}
+
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if _, err := os.Stat(".hfl/base/2025-08-16.md"); err != nil {
+		t.Errorf("Expected base file to be written: %v", err)
+	}
+
+	// Base survives a reload
+	loaded, err := Load("notion")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if base, ok := loaded.Base("2025-08-16"); !ok || base != "Synced body" {
+		t.Errorf("Expected saved base 'Synced body', got %q (%v)", base, ok)
+	}
+
+	// A base that doesn't match the last-synced hash is not trusted
+	entry := loaded.Entries["2025-08-16"]
+	entry.Hash = calculateHash("Something else")
+	loaded.Entries["2025-08-16"] = entry
+
+	if _, ok := loaded.Base("2025-08-16"); ok {
+		t.Error("Expected stale base to be rejected")
+	}
+}
+
+func TestConflictResolution(t *testing.T) {
+	state := &State{
+		Entries: make(map[string]EntryState),
+	}
+
+	state.UpdateEntry("2025-08-16", "Synced")
+	state.UpdateEntry("2025-08-15", "Synced")
+	state.MarkConflict("2025-08-16")
+	state.MarkConflict("2025-08-15")
+
+	conflicts := state.Conflicts()
+	if len(conflicts) != 2 || conflicts[0] != "2025-08-15" {
+		t.Errorf("Expected both conflicts oldest first, got %v", conflicts)
+	}
+
+	entry := state.Entries["2025-08-16"]
+	if entry.CanPush() || entry.CanPull() {
+		t.Error("Expected unresolved conflict to block push and pull")
+	}
+
+	// Resolution survives marking the conflict again
+	state.Resolve("2025-08-16", ResolutionLocal)
+	state.MarkConflict("2025-08-16")
+
+	entry = state.Entries["2025-08-16"]
+	if !entry.CanPush() || entry.CanPull() {
+		t.Error("Expected local resolution to allow push only")
+	}
+
+	// Syncing the entry settles the conflict
+	state.UpdateEntry("2025-08-16", "Local wins")
+
+	entry = state.Entries["2025-08-16"]
+	if entry.Conflict || entry.Resolution != "" {
+		t.Error("Expected UpdateEntry to clear the conflict")
+	}
+
+	state.ClearConflict("2025-08-15")
+	if len(state.Conflicts()) != 0 {
+		t.Errorf("Expected no conflicts left, got %v", state.Conflicts())
+	}
+}
+
+func TestRecordDeletions(t *testing.T) {
+	state := &State{
+		Entries: make(map[string]EntryState),
+	}
+
+	state.UpdateEntry("2025-08-16", "Kept")
+	state.UpdateEntry("2025-08-15", "Deleted")
+	state.SetRemoteID("2025-08-15", "abc123")
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Kept"}}}
+
+	tombstones := state.RecordDeletions(journal)
+	if len(tombstones) != 1 || tombstones[0] != "2025-08-15" {
+		t.Fatalf("Expected 2025-08-15 to be tombstoned, got %v", tombstones)
+	}
+
+	entry := state.Entries["2025-08-15"]
+	if entry.DeletedAt == "" || entry.RemoteID != "abc123" {
+		t.Error("Expected tombstone to keep the remote_id until the page is archived")
+	}
+
+	// Recording again keeps the original deletion time
+	deletedAt := entry.DeletedAt
+	state.RecordDeletions(journal)
+	if state.Entries["2025-08-15"].DeletedAt != deletedAt {
+		t.Error("Expected deletion time to be preserved")
+	}
+
+	// Restoring the entry lifts the tombstone
+	journal.Entries = append(journal.Entries, parser.Entry{Date: "2025-08-15", Body: "Back"})
+	if tombstones := state.RecordDeletions(journal); len(tombstones) != 0 {
+		t.Errorf("Expected no tombstones after restoring the entry, got %v", tombstones)
+	}
+}
+
+func TestRemoveEntry(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalDir)
+
+	state := &State{
+		Entries: make(map[string]EntryState),
+	}
+
+	state.UpdateEntry("2025-08-16", "Body")
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	state.RemoveEntry("2025-08-16")
+
+	if _, exists := state.GetEntry("2025-08-16"); exists {
+		t.Error("Expected entry to be removed")
+	}
+
+	if _, err := os.Stat(".hfl/base/2025-08-16.md"); !os.IsNotExist(err) {
+		t.Error("Expected base file to be removed")
+	}
+}
+
+func TestUnlink(t *testing.T) {
+	state := &State{
+		Entries: make(map[string]EntryState),
+	}
+
+	state.SetRemoteID("2025-08-16", "page-1")
+	state.UpdateEntry("2025-08-16", "Body")
+	state.SetRemote("2025-08-16", "2025-08-16T10:00:00Z", "hash")
+	state.MarkConflict("2025-08-16")
+
+	state.Unlink("2025-08-16")
+
+	entry, _ := state.GetEntry("2025-08-16")
+	if entry.RemoteID != "" || entry.RemoteHash != "" || entry.LastRemoteEdit != "" {
+		t.Errorf("Expected remote fields to be cleared, got %+v", entry)
+	}
+	if entry.Conflict {
+		t.Error("Expected conflict to be cleared")
+	}
+	if !entry.Unlinked {
+		t.Error("Expected entry to be unlinked")
+	}
+	if state.HasChanged("2025-08-16", "Body") {
+		t.Error("Expected local hash to be kept")
+	}
+}
+
+func TestSave_ResumableRun(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	tempDir := t.TempDir()
+	os.Chdir(tempDir)
+	defer os.Chdir(originalDir)
+
+	state := &State{
+		Entries: make(map[string]EntryState),
+	}
+
+	state.BeginRun("push")
+	state.MarkPendingCreate("2025-08-16")
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load("notion")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Run == nil || loaded.Run.Mode != "push" {
+		t.Errorf("Expected interrupted push to be recorded, got %+v", loaded.Run)
+	}
+	if loaded.Entries["2025-08-16"].PendingCreate == "" {
+		t.Error("Expected pending create to be saved")
+	}
+
+	if _, err := os.Stat(".hfl/state.json.tmp"); !os.IsNotExist(err) {
+		t.Error("Expected temporary state file to be renamed away")
+	}
+
+	loaded.EndRun()
+	loaded.ClearPendingCreate("2025-08-16")
+	if loaded.Run != nil || loaded.Entries["2025-08-16"].PendingCreate != "" {
+		t.Error("Expected run and pending create to be cleared")
+	}
+}
+
+func TestLoad_LegacyNotionID(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(originalDir)
+
+	os.MkdirAll(".hfl", 0755)
+	data := `{"entries": {"2025-08-16": {"notion_id": "page-1", "last_local_sync": ""}}}`
+	if err := os.WriteFile(".hfl/state.json", []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := Load("notion")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if entry := state.Entries["2025-08-16"]; entry.RemoteID != "page-1" {
+		t.Errorf("Expected notion_id to be read as the remote ID, got %q", entry.RemoteID)
+	}
+}
+
+func TestLoad_KeyedByBackend(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(originalDir)
+
+	other, err := Load("obsidian")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	other.SetRemoteID("2025-08-16", "Daily/2025-08-16.md")
+	other.UpdateEntry("2025-08-16", "Body")
+	if err := other.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	for _, path := range []string{".hfl/obsidian/state.json", ".hfl/obsidian/base/2025-08-16.md"} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be written: %v", path, err)
+		}
+	}
+
+	notion, err := Load("notion")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if len(notion.Entries) != 0 {
+		t.Errorf("Expected backends not to share state, got %v", notion.Entries)
+	}
+
+	reloaded, err := Load("obsidian")
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if body, ok := reloaded.Base("2025-08-16"); !ok || body != "Body" {
+		t.Errorf("Expected the base to be read from the backend's directory, got %q", body)
+	}
+}
@@ -0,0 +1,87 @@
+// Package sync keeps hfl.md in step with a backend: change detection,
+// conflicts, remote deletes and the dry-run plan are shared, and each
+// backend only stores and fetches documents.
+package sync
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+)
+
+// ErrNotFound is wrapped by backend errors for documents that don't exist
+// or were deleted
+var ErrNotFound = errors.New("document not found")
+
+// Unreachable reports whether err comes from a backend that couldn't be
+// reached at all, e.g. while offline
+func Unreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Statuses a StatusMarker shows on a document
+const (
+	StatusSynced   = "Synced"
+	StatusConflict = "Conflict"
+)
+
+// Document is an entry as a backend stores it
+type Document struct {
+	ID       string
+	Date     string // "" when the document has no valid date
+	Body     string // only filled in by Get, Create and Update
+	EditedAt time.Time
+	Deleted  bool   // archived or trashed, but still reachable
+	Title    string // title hfl wrote for the document, if the backend has one
+}
+
+// Changes is the result of Backend.Changes
+type Changes struct {
+	Documents []Document
+	Cursor    string // passed to the next call of Changes
+
+	// Complete reports whether Documents lists every live document, so a
+	// linked entry missing from it may have been deleted remotely
+	Complete bool
+}
+
+// Backend is a place journal entries are synced with
+type Backend interface {
+	// Name keys the backend's state, e.g. "notion"
+	Name() string
+
+	// List returns every live document, without bodies
+	List() ([]Document, error)
+
+	// Get returns a document with its body. A deleted document that can
+	// still be reached comes back with Deleted set and no body.
+	Get(id string) (Document, error)
+
+	// Create stores a new document for an entry
+	Create(entry parser.Entry) (Document, error)
+
+	// Update replaces a document's body with the entry's. previous is
+	// what state knew about the entry before the update.
+	Update(id string, entry parser.Entry, previous state.EntryState) (Document, error)
+
+	// Delete removes a document
+	Delete(id string) error
+
+	// Changes returns the documents edited since cursor, without bodies.
+	// An empty cursor asks for every document.
+	Changes(cursor string) (Changes, error)
+
+	// Normalize returns body as the backend would give it back after
+	// storing it, so a push followed by a pull isn't seen as a change
+	Normalize(body string) string
+}
+
+// StatusMarker is implemented by backends that show the sync status of
+// each document, such as Notion's Sync Status property
+type StatusMarker interface {
+	MarkStatus(id, body, status string) (Document, error)
+}
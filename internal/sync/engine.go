@@ -0,0 +1,874 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ahmaruff/hfl/internal/merge"
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+)
+
+// Options tune an Engine
+type Options struct {
+	// OnRemoteDelete decides what happens to a local entry whose document
+	// was deleted remotely: "unlink" (default), "delete" or "recreate"
+	OnRemoteDelete string
+}
+
+// Engine syncs a journal with a backend. An engine is meant for one run:
+// the backend is listed once and the listing shared by the duplicate
+// check, conflict detection and the pull.
+type Engine struct {
+	backend Backend
+	options Options
+	report  []ReportItem
+
+	// changes is the listing of this run, until a pull consumes it
+	changes *Changes
+	// byDate indexes the live documents for adopting existing ones
+	byDate map[string][]Document
+}
+
+func NewEngine(backend Backend, options Options) *Engine {
+	return &Engine{backend: backend, options: options}
+}
+
+// Backend returns the backend the engine syncs with
+func (e *Engine) Backend() Backend {
+	return e.backend
+}
+
+// Push sends local changes to the backend. Changes queued by an earlier
+// failed sync go first, in the order they were queued. When a change can't
+// be sent, every change not sent yet is queued before the error returns.
+func (e *Engine) Push(journal *parser.Journal, syncState *state.State) error {
+	if err := e.drain(journal, syncState); err != nil {
+		e.QueuePending(journal, syncState, err)
+		return err
+	}
+
+	for _, entry := range journal.Entries {
+		entryState, _ := syncState.GetEntry(entry.Date)
+
+		if merge.HasConflictMarkers(entry.Body) {
+			fmt.Printf("Skipping %s: unresolved merge conflict markers\n", entry.Date)
+			continue
+		}
+
+		if !entryState.CanPush() {
+			fmt.Printf("Skipping %s: unresolved conflict (run 'hfl resolve')\n", entry.Date)
+			continue
+		}
+
+		if entryState.Unlinked {
+			continue
+		}
+
+		if err := e.pushChange(entry, syncState); err != nil {
+			e.QueuePending(journal, syncState, err)
+			return err
+		}
+	}
+
+	// Update last sync time
+	syncState.SetLastSynced(time.Now().Format(time.RFC3339))
+	return syncState.Save()
+}
+
+// pushChange sends an entry to the backend if it is new or changed since
+// the last sync, and takes it off the queue
+func (e *Engine) pushChange(entry parser.Entry, syncState *state.State) error {
+	entryState, exists := syncState.GetEntry(entry.Date)
+
+	switch {
+	case !exists || entryState.RemoteID == "":
+		if err := e.pushNew(entry, entryState, syncState); err != nil {
+			return fmt.Errorf("failed to create entry %s: %w", entry.Date, err)
+		}
+	case syncState.HasChanged(entry.Date, entry.Body):
+		err := e.update(entry, entryState, syncState)
+		if errors.Is(err, ErrNotFound) {
+			// The pull applies the on_remote_delete policy to it
+			e.record(entry.Date, ActionSkipped, fmt.Sprintf("deleted in %s; pull to apply the remote delete policy", e.backend.Name()))
+		} else if err != nil {
+			return fmt.Errorf("failed to update entry %s: %w", entry.Date, err)
+		} else {
+			e.record(entry.Date, ActionUpdated, "")
+		}
+	default:
+		// Already in sync
+		syncState.Dequeue(entry.Date)
+		return nil
+	}
+
+	// Save after every remote change, so a failure later on doesn't lose
+	// what was already pushed
+	syncState.Dequeue(entry.Date)
+	return syncState.Save()
+}
+
+// drain pushes the queued changes. Each one sends the entry as it is now,
+// so a change whose entry was deleted or already synced since is dropped,
+// and one whose entry can't be pushed yet, e.g. because of a conflict,
+// stays queued.
+func (e *Engine) drain(journal *parser.Journal, syncState *state.State) error {
+	queue := append([]state.QueuedOp(nil), syncState.Queue...)
+
+	for _, queued := range queue {
+		if queued.Op == state.OpDelete {
+			if entryState, ok := syncState.GetEntry(queued.Date); ok && entryState.DeletedAt != "" {
+				if err := e.Archive(syncState, queued.Date); err != nil {
+					return err
+				}
+				continue
+			}
+			syncState.Dequeue(queued.Date)
+			continue
+		}
+
+		index := entryIndex(journal, queued.Date)
+		if index < 0 {
+			syncState.Dequeue(queued.Date)
+			continue
+		}
+		entry := journal.Entries[index]
+
+		entryState, _ := syncState.GetEntry(entry.Date)
+		if merge.HasConflictMarkers(entry.Body) || !entryState.CanPush() {
+			continue
+		}
+		if entryState.Unlinked {
+			syncState.Dequeue(entry.Date)
+			continue
+		}
+
+		if err := e.pushChange(entry, syncState); err != nil {
+			return err
+		}
+	}
+
+	return syncState.Save()
+}
+
+// QueuePending queues every local change not pushed yet, with the error
+// that kept it from being pushed, and saves state. It returns the length
+// of the queue.
+func (e *Engine) QueuePending(journal *parser.Journal, syncState *state.State, reason error) int {
+	for _, entry := range journal.Entries {
+		entryState, exists := syncState.GetEntry(entry.Date)
+		if entryState.Unlinked || !entryState.CanPush() || merge.HasConflictMarkers(entry.Body) {
+			continue
+		}
+
+		switch {
+		case !exists || entryState.RemoteID == "":
+			syncState.Enqueue(state.OpCreate, entry.Date, entry.Body, reason)
+		case syncState.HasChanged(entry.Date, entry.Body):
+			syncState.Enqueue(state.OpUpdate, entry.Date, entry.Body, reason)
+		}
+	}
+
+	if err := syncState.Save(); err != nil {
+		fmt.Printf("Warning: failed to save the queue: %v\n", err)
+	}
+	return len(syncState.Queue)
+}
+
+// Pull brings remote changes into journal. State is not saved: the caller
+// saves it after writing hfl.md, so state never records a body the file
+// doesn't have.
+func (e *Engine) Pull(journal *parser.Journal, syncState *state.State) error {
+	changes, err := e.listing(syncState)
+	if err != nil {
+		return fmt.Errorf("failed to pull documents: %w", err)
+	}
+	// The cursor moves on, so a later pull lists again
+	e.changes = nil
+
+	fmt.Printf("Found %d documents in %s\n", len(changes.Documents), e.backend.Name())
+
+	// Documents are grouped by date first, so dates with more than one
+	// document are known before any of them is applied
+	var documents []Document
+	seen := make(map[string]bool)
+	perDate := make(map[string]int)
+	for _, document := range changes.Documents {
+		if document.Deleted {
+			// Left out of seen, so the check below applies the policy
+			continue
+		}
+		seen[document.ID] = true
+
+		if document.Date != "" {
+			perDate[document.Date]++
+			documents = append(documents, document)
+		}
+	}
+
+	reported := make(map[string]bool)
+	for _, document := range documents {
+		if perDate[document.Date] > 1 {
+			if !reported[document.Date] {
+				fmt.Printf("Skipping %s: %d documents in %s\n", document.Date, perDate[document.Date], e.backend.Name())
+				e.record(document.Date, ActionSkipped, "duplicate documents in "+e.backend.Name())
+				reported[document.Date] = true
+			}
+			continue
+		}
+
+		// Only a full listing shows whether the document linked to the
+		// date is still there; until then this one may be a duplicate
+		if linked := syncState.Entries[document.Date].RemoteID; !changes.Complete && linked != "" && linked != document.ID {
+			fmt.Printf("Skipping %s: another document in %s is linked to it\n", document.Date, e.backend.Name())
+			e.record(document.Date, ActionSkipped, "not the linked document; checked again on the next full listing")
+			continue
+		}
+
+		if err := e.pullDocument(journal, syncState, document); err != nil {
+			return err
+		}
+	}
+
+	if changes.Complete {
+		e.handleRemoteDeletes(journal, syncState, seen)
+	}
+
+	fmt.Printf("Pull from %s done\n", e.backend.Name())
+
+	syncState.Cursor = changes.Cursor
+	syncState.SetLastSynced(time.Now().Format(time.RFC3339))
+	return nil
+}
+
+// listing returns the documents of this run: the changes since the last
+// pull, fetched once and shared until a pull consumes them
+func (e *Engine) listing(syncState *state.State) (Changes, error) {
+	if e.changes != nil {
+		return *e.changes, nil
+	}
+
+	changes, err := e.backend.Changes(syncState.Cursor)
+	if err != nil {
+		return Changes{}, err
+	}
+	e.changes = &changes
+	return changes, nil
+}
+
+// pullDocument applies one document to the entry of its date, if it changed
+func (e *Engine) pullDocument(journal *parser.Journal, syncState *state.State, document Document) error {
+	date := document.Date
+
+	// Only fetch bodies of documents that were edited since the last sync
+	entryState, exists := syncState.GetEntry(date)
+	if entryState.DeletedAt != "" {
+		// Deleted locally; don't bring it back before it is pruned
+		return nil
+	}
+
+	if entryState.Unlinked {
+		return nil
+	}
+
+	if entryState.PendingCreate != "" {
+		// Its document may be missing part of the body; the push
+		// finishes it from hfl.md
+		return nil
+	}
+
+	if !entryState.CanPull() {
+		fmt.Printf("Skipping %s: unresolved conflict (run 'hfl resolve')\n", date)
+		return nil
+	}
+
+	changed, remote, err := e.remoteChanged(document, entryState)
+	if err != nil {
+		fmt.Printf("Warning: failed to get content for %s: %v\n", date, err)
+		return nil
+	}
+
+	if exists && entryState.RemoteID == document.ID && !changed {
+		if remote != nil {
+			// Edited without changing the body, e.g. a property
+			syncState.SetRemote(date, formatTime(remote.EditedAt), entryState.RemoteHash)
+		}
+		return nil
+	}
+
+	if remote == nil {
+		fetched, err := e.backend.Get(document.ID)
+		if err != nil {
+			fmt.Printf("Warning: failed to get content for %s: %v\n", date, err)
+			return nil
+		}
+		remote = &fetched
+	}
+
+	e.updateLocalEntry(journal, date, *remote, syncState)
+	return nil
+}
+
+// Conflict is an entry that was modified both locally and remotely since
+// it was last synced
+type Conflict struct {
+	Date           string
+	RemoteID       string
+	RemoteEditedAt time.Time
+}
+
+// DetectConflicts finds entries whose local body and remote document both
+// changed since the last sync, and marks their documents as conflicted on
+// backends that show a status. Documents missing from a partial listing
+// weren't edited since the last pull, so they can't conflict.
+func (e *Engine) DetectConflicts(journal *parser.Journal, syncState *state.State) ([]Conflict, error) {
+	changes, err := e.listing(syncState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	documents := make(map[string]Document, len(changes.Documents))
+	for _, document := range changes.Documents {
+		if document.Deleted {
+			continue
+		}
+		documents[document.ID] = document
+	}
+
+	var conflicts []Conflict
+	for _, entry := range journal.Entries {
+		entryState, exists := syncState.GetEntry(entry.Date)
+		if !exists || entryState.RemoteID == "" {
+			continue
+		}
+
+		if !syncState.HasChanged(entry.Date, entry.Body) {
+			// The local side was reverted, so there's nothing left to resolve
+			if entryState.Conflict {
+				syncState.ClearConflict(entry.Date)
+			}
+			continue
+		}
+
+		document, ok := documents[entryState.RemoteID]
+		if !ok {
+			continue
+		}
+
+		changed, remote, err := e.remoteChanged(document, entryState)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check remote changes for %s: %w", entry.Date, err)
+		}
+		if !changed {
+			continue
+		}
+
+		conflict := Conflict{
+			Date:           entry.Date,
+			RemoteID:       document.ID,
+			RemoteEditedAt: document.EditedAt,
+		}
+		if remote != nil {
+			conflict.RemoteEditedAt = remote.EditedAt
+		}
+
+		if marker, ok := e.backend.(StatusMarker); ok && remote != nil {
+			if marked, err := marker.MarkStatus(document.ID, remote.Body, StatusConflict); err != nil {
+				fmt.Printf("Warning: failed to mark %s as conflicted: %v\n", entry.Date, err)
+			} else if !marked.EditedAt.IsZero() {
+				// Marking the document edits it too
+				conflict.RemoteEditedAt = marked.EditedAt
+			}
+		}
+
+		conflicts = append(conflicts, conflict)
+		syncState.MarkConflict(entry.Date)
+	}
+
+	return conflicts, nil
+}
+
+// MergeConflicts runs a three-way merge of each conflicted entry against
+// the body recorded at the last sync. Clean merges replace the local body
+// and are pushed by the next Push. Overlapping changes are written into
+// the local body with conflict markers, which Push refuses to send until
+// they are resolved. It returns the dates left unresolved.
+func (e *Engine) MergeConflicts(journal *parser.Journal, syncState *state.State, conflicts []Conflict) ([]string, error) {
+	var unresolved []string
+
+	for _, conflict := range conflicts {
+		index := entryIndex(journal, conflict.Date)
+		if index < 0 {
+			continue
+		}
+
+		remote, err := e.Content(conflict.RemoteID)
+		if err != nil {
+			return unresolved, fmt.Errorf("failed to get content for %s: %w", conflict.Date, err)
+		}
+
+		base, ok := syncState.Base(conflict.Date)
+		if !ok {
+			fmt.Printf("Warning: no common ancestor for %s, merging without one\n", conflict.Date)
+		}
+
+		result := merge.Merge(base, journal.Entries[index].Body, remote)
+		journal.Entries[index].Body = result.Text
+
+		// The remote side is now part of the local body
+		syncState.SetRemote(conflict.Date, formatTime(conflict.RemoteEditedAt), state.HashContent(remote))
+		syncState.Resolve(conflict.Date, state.ResolutionLocal)
+
+		if result.Conflicts > 0 {
+			unresolved = append(unresolved, conflict.Date)
+		}
+	}
+
+	return unresolved, nil
+}
+
+// Archive deletes the remote document of an entry deleted from hfl.md.
+// The entry is removed from state only once the delete succeeded, and
+// queued otherwise; a document that is already gone counts as deleted.
+func (e *Engine) Archive(syncState *state.State, date string) error {
+	entryState, exists := syncState.GetEntry(date)
+	if !exists {
+		return nil
+	}
+
+	if entryState.RemoteID != "" {
+		if err := e.backend.Delete(entryState.RemoteID); err != nil && !errors.Is(err, ErrNotFound) {
+			// Queued, so the next sync retries it even without --prune
+			syncState.Enqueue(state.OpDelete, date, "", err)
+			syncState.Save()
+			return fmt.Errorf("failed to archive document for %s: %w", date, err)
+		}
+	}
+
+	e.indexRemove(entryState.RemoteID)
+	e.record(date, ActionArchived, "deleted from hfl.md")
+	syncState.RemoveEntry(date)
+	syncState.Dequeue(date)
+	return syncState.Save()
+}
+
+// Content returns the body of a remote document
+func (e *Engine) Content(id string) (string, error) {
+	document, err := e.backend.Get(id)
+	if err != nil {
+		return "", err
+	}
+	return document.Body, nil
+}
+
+// PushEntry pushes a single entry regardless of whether it changed, and
+// saves state right away
+func (e *Engine) PushEntry(journal *parser.Journal, syncState *state.State, date string) error {
+	index := entryIndex(journal, date)
+	if index < 0 {
+		return fmt.Errorf("entry %s not found in journal", date)
+	}
+	entry := journal.Entries[index]
+
+	entryState, exists := syncState.GetEntry(date)
+	if !exists || entryState.RemoteID == "" {
+		if err := e.pushNew(entry, entryState, syncState); err != nil {
+			return fmt.Errorf("failed to create entry %s: %w", date, err)
+		}
+	} else if err := e.update(entry, entryState, syncState); err != nil {
+		return fmt.Errorf("failed to update entry %s: %w", date, err)
+	}
+
+	return syncState.Save()
+}
+
+// PullEntry overwrites a single local entry with its remote document.
+// Like Pull, it leaves saving state to the caller.
+func (e *Engine) PullEntry(journal *parser.Journal, syncState *state.State, date string) error {
+	entryState, exists := syncState.GetEntry(date)
+	if !exists || entryState.RemoteID == "" {
+		return fmt.Errorf("entry %s is not linked to a %s document", date, e.backend.Name())
+	}
+
+	remote, err := e.backend.Get(entryState.RemoteID)
+	if err != nil {
+		return fmt.Errorf("failed to get document for %s: %w", date, err)
+	}
+
+	e.updateLocalEntry(journal, date, remote, syncState)
+	return nil
+}
+
+// handleRemoteDeletes applies the on_remote_delete policy to linked
+// entries whose document didn't come back from the listing. Each one is
+// looked up first, since only a deleted or unreachable document counts.
+// Entries edited locally since the last sync are never deleted.
+func (e *Engine) handleRemoteDeletes(journal *parser.Journal, syncState *state.State, seen map[string]bool) {
+	var dates []string
+	for date, entry := range syncState.Entries {
+		if entry.RemoteID != "" && entry.DeletedAt == "" && !seen[entry.RemoteID] {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+
+	name := e.backend.Name()
+	for _, date := range dates {
+		document, err := e.backend.Get(syncState.Entries[date].RemoteID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			fmt.Printf("Warning: failed to look up missing document for %s: %v\n", date, err)
+			continue
+		}
+		if err == nil && !document.Deleted {
+			continue
+		}
+
+		e.indexRemove(syncState.Entries[date].RemoteID)
+		index := entryIndex(journal, date)
+
+		policy := e.options.OnRemoteDelete
+		if policy == "delete" && index >= 0 && syncState.HasChanged(date, journal.Entries[index].Body) {
+			policy = "unlink"
+		}
+
+		switch policy {
+		case "delete":
+			if index >= 0 {
+				journal.Entries = append(journal.Entries[:index], journal.Entries[index+1:]...)
+			}
+			syncState.RemoveEntry(date)
+			e.record(date, ActionDeleted, "deleted in "+name+"; removed from hfl.md")
+		case "recreate":
+			syncState.Detach(date)
+			e.record(date, ActionRecreate, "deleted in "+name+"; recreated on the next push")
+		default:
+			syncState.Unlink(date)
+			e.record(date, ActionUnlinked, "deleted in "+name+"; kept in hfl.md only")
+		}
+	}
+}
+
+// remoteChanged reports whether a document's body changed since the last
+// sync. The edit time is checked first so unchanged documents cost no
+// extra requests; when it moved, the body is fetched and its hash
+// compared, since edits to properties alone also move the time. The
+// fetched document is returned so callers don't have to fetch it twice.
+func (e *Engine) remoteChanged(document Document, entryState state.EntryState) (bool, *Document, error) {
+	if entryState.RemoteID != document.ID {
+		return true, nil, nil
+	}
+
+	if unchangedSince(document.EditedAt, entryState) {
+		return false, nil, nil
+	}
+
+	remote, err := e.backend.Get(document.ID)
+	if err != nil {
+		return false, nil, err
+	}
+
+	// Entries synced before remote hashes were tracked only have a local hash
+	previous := entryState.RemoteHash
+	if previous == "" {
+		previous = entryState.Hash
+	}
+
+	return state.HashContent(remote.Body) != previous, &remote, nil
+}
+
+// unchangedSince reports whether editedAt shows no edit after the one
+// recorded in state. Notion rounds edit times down to the minute, so an
+// equal time recorded within the minute of the edit is not trusted: a
+// later edit in the same minute wouldn't move it.
+func unchangedSince(editedAt time.Time, entryState state.EntryState) bool {
+	lastEdit, err := time.Parse(time.RFC3339, entryState.LastRemoteEdit)
+	if err != nil || editedAt.After(lastEdit) {
+		return false
+	}
+	if editedAt.Before(lastEdit) {
+		return true
+	}
+
+	seenAt, err := time.Parse(time.RFC3339, entryState.RemoteSeenAt)
+	return err == nil && !seenAt.Before(lastEdit.Add(time.Minute))
+}
+
+// pushNew creates the document of an entry not linked yet. A document
+// that already exists for the date is adopted instead. A pending marker
+// is saved before the document is created and cleared once its ID is
+// saved, so if a crash falls in between, the next run finds the document
+// instead of creating a duplicate.
+func (e *Engine) pushNew(entry parser.Entry, entryState state.EntryState, syncState *state.State) error {
+	document, err := e.findByDate(entry.Date)
+	if err != nil {
+		return fmt.Errorf("failed to look up existing document: %w", err)
+	}
+
+	if document != nil && entryState.PendingCreate != "" {
+		// Created by an interrupted sync and possibly with an older body,
+		// so push the current one
+		syncState.SetRemoteID(entry.Date, document.ID)
+		syncState.ClearPendingCreate(entry.Date)
+
+		entryState, _ = syncState.GetEntry(entry.Date)
+		if err := e.update(entry, entryState, syncState); err != nil {
+			return err
+		}
+
+		e.record(entry.Date, ActionRecovered, "document from an interrupted sync")
+		return syncState.Save()
+	}
+
+	if document != nil {
+		if err := e.adopt(entry, *document, syncState); err != nil {
+			return err
+		}
+		return syncState.Save()
+	}
+
+	syncState.MarkPendingCreate(entry.Date)
+	if err := syncState.Save(); err != nil {
+		return err
+	}
+
+	created, err := e.backend.Create(entry)
+	if err != nil {
+		return err
+	}
+	stored(entry, created, syncState)
+	e.indexAdd(created)
+
+	syncState.ClearPendingCreate(entry.Date)
+	e.record(entry.Date, ActionCreated, "")
+	return syncState.Save()
+}
+
+// update writes an entry over its linked document
+func (e *Engine) update(entry parser.Entry, entryState state.EntryState, syncState *state.State) error {
+	if entryState.RemoteID == "" {
+		return fmt.Errorf("no %s document for entry %s", e.backend.Name(), entry.Date)
+	}
+
+	updated, err := e.backend.Update(entryState.RemoteID, entry, entryState)
+	if err != nil {
+		return err
+	}
+
+	stored(entry, updated, syncState)
+	return nil
+}
+
+// stored records that an entry's body was written to a document
+func stored(entry parser.Entry, document Document, syncState *state.State) {
+	syncState.SetRemoteID(entry.Date, document.ID)
+	syncState.SetTitle(entry.Date, document.Title)
+	syncState.UpdateEntry(entry.Date, entry.Body)
+	syncState.SetRemote(entry.Date, formatTime(document.EditedAt), state.HashContent(document.Body))
+}
+
+// AdoptExisting links local entries that have no document in state to
+// the one the backend already has for their date, e.g. after a fresh
+// clone or a deleted .hfl directory. Run it before a pull, which would
+// otherwise overwrite such entries with the remote body. Entries whose
+// creation was interrupted are left to the push, which finishes writing
+// their document.
+func (e *Engine) AdoptExisting(journal *parser.Journal, syncState *state.State) error {
+	for _, entry := range journal.Entries {
+		entryState, _ := syncState.GetEntry(entry.Date)
+		if entryState.RemoteID != "" || entryState.Unlinked || entryState.PendingCreate != "" {
+			continue
+		}
+
+		document, err := e.findByDate(entry.Date)
+		if err != nil {
+			return fmt.Errorf("failed to look up existing document: %w", err)
+		}
+		if document == nil {
+			continue
+		}
+
+		if err := e.adopt(entry, *document, syncState); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Relink rebuilds state from scratch by matching every local entry to its
+// remote document
+func (e *Engine) Relink(journal *parser.Journal, syncState *state.State) error {
+	syncState.Reset()
+	return e.AdoptExisting(journal, syncState)
+}
+
+// adopt links an entry to an existing document. When their bodies match
+// the entry is in sync; otherwise it is flagged as a conflict, so the
+// difference goes through conflict resolution instead of one side
+// overwriting the other.
+func (e *Engine) adopt(entry parser.Entry, document Document, syncState *state.State) error {
+	remote, err := e.backend.Get(document.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get content for %s: %w", entry.Date, err)
+	}
+
+	syncState.SetRemoteID(entry.Date, document.ID)
+	syncState.ClearPendingCreate(entry.Date)
+
+	if remote.Body == e.backend.Normalize(entry.Body) {
+		syncState.UpdateEntry(entry.Date, entry.Body)
+		syncState.SetRemote(entry.Date, formatTime(remote.EditedAt), state.HashContent(remote.Body))
+		e.record(entry.Date, ActionLinked, "existing document")
+		return nil
+	}
+
+	syncState.MarkConflict(entry.Date)
+	e.record(entry.Date, ActionLinked, "existing document differs from hfl.md; resolve it as a conflict")
+	return nil
+}
+
+// Duplicate is a date with more than one live document
+type Duplicate struct {
+	Date string
+	IDs  []string
+}
+
+// FindDuplicates returns every date with more than one live document,
+// oldest date first. Entries of those dates can't be matched to a
+// document. Only a full listing covers the whole backend; a partial one
+// is checked for duplicates among the documents edited since the last
+// pull, and the rest wait for the next full listing.
+func (e *Engine) FindDuplicates(syncState *state.State) ([]Duplicate, error) {
+	changes, err := e.listing(syncState)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	byDate := groupByDate(changes.Documents)
+	if changes.Complete && e.byDate == nil {
+		e.byDate = byDate
+	}
+
+	var duplicates []Duplicate
+	for date, documents := range byDate {
+		if len(documents) < 2 {
+			continue
+		}
+
+		duplicate := Duplicate{Date: date}
+		for _, document := range documents {
+			duplicate.IDs = append(duplicate.IDs, document.ID)
+		}
+		duplicates = append(duplicates, duplicate)
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i].Date < duplicates[j].Date
+	})
+	return duplicates, nil
+}
+
+// findByDate returns a live document for date, or nil
+func (e *Engine) findByDate(date string) (*Document, error) {
+	byDate, err := e.index()
+	if err != nil {
+		return nil, err
+	}
+
+	documents := byDate[date]
+	if len(documents) == 0 {
+		return nil, nil
+	}
+	return &documents[0], nil
+}
+
+// index groups the live documents by date. A full listing of this run is
+// reused; otherwise the backend is listed. The index is kept for the rest
+// of the run.
+func (e *Engine) index() (map[string][]Document, error) {
+	if e.byDate != nil {
+		return e.byDate, nil
+	}
+
+	if e.changes != nil && e.changes.Complete {
+		e.byDate = groupByDate(e.changes.Documents)
+		return e.byDate, nil
+	}
+
+	documents, err := e.backend.List()
+	if err != nil {
+		return nil, err
+	}
+
+	e.byDate = groupByDate(documents)
+	return e.byDate, nil
+}
+
+// indexAdd adds a document the engine created to the index
+func (e *Engine) indexAdd(document Document) {
+	if e.byDate != nil && document.Date != "" {
+		e.byDate[document.Date] = append(e.byDate[document.Date], document)
+	}
+}
+
+// indexRemove drops a deleted document from the index
+func (e *Engine) indexRemove(id string) {
+	for date, documents := range e.byDate {
+		for i, document := range documents {
+			if document.ID == id {
+				e.byDate[date] = append(documents[:i:i], documents[i+1:]...)
+			}
+		}
+	}
+}
+
+// groupByDate groups the live, dated documents by date
+func groupByDate(documents []Document) map[string][]Document {
+	byDate := make(map[string][]Document)
+	for _, document := range documents {
+		if !document.Deleted && document.Date != "" {
+			byDate[document.Date] = append(byDate[document.Date], document)
+		}
+	}
+	return byDate
+}
+
+// updateLocalEntry writes a remote body into the journal and records it
+// as synced
+func (e *Engine) updateLocalEntry(journal *parser.Journal, date string, remote Document, syncState *state.State) {
+	if index := entryIndex(journal, date); index >= 0 {
+		journal.Entries[index].Body = remote.Body
+	} else {
+		journal.Entries = append(journal.Entries, parser.Entry{Date: date, Body: remote.Body})
+	}
+
+	editedAt := remote.EditedAt
+	if marker, ok := e.backend.(StatusMarker); ok {
+		if marked, err := marker.MarkStatus(remote.ID, remote.Body, StatusSynced); err != nil {
+			fmt.Printf("Warning: failed to update metadata for %s: %v\n", date, err)
+		} else if !marked.EditedAt.IsZero() {
+			editedAt = marked.EditedAt
+		}
+	}
+
+	syncState.SetRemoteID(date, remote.ID)
+	syncState.UpdateEntry(date, remote.Body)
+	syncState.SetRemote(date, formatTime(editedAt), state.HashContent(remote.Body))
+	e.record(date, ActionPulled, "")
+}
+
+// entryIndex returns the index of the entry for date, or -1
+func entryIndex(journal *parser.Journal, date string) int {
+	for i, entry := range journal.Entries {
+		if entry.Date == date {
+			return i
+		}
+	}
+	return -1
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,494 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ahmaruff/hfl/internal/parser"
+	"github.com/ahmaruff/hfl/internal/state"
+)
+
+// memoryBackend keeps documents in a map. Every write advances its clock
+// by a minute, unless sameMinute is set.
+type memoryBackend struct {
+	now       time.Time
+	nextID    int
+	documents map[string]*Document
+
+	// sameMinute keeps the clock still, like Notion's edit times for
+	// writes within the same minute
+	sameMinute bool
+	// incomplete makes Changes report a partial listing
+	incomplete bool
+	// offline makes every call fail as if the network was down
+	offline bool
+	// listings counts the calls to List and Changes
+	listings int
+}
+
+// errOffline is what a request fails with when there's no network
+var errOffline = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("network is unreachable")}
+
+func newMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		now:       time.Date(2025, 8, 16, 10, 0, 0, 0, time.UTC),
+		documents: make(map[string]*Document),
+	}
+}
+
+func (m *memoryBackend) Name() string { return "memory" }
+
+func (m *memoryBackend) List() ([]Document, error) {
+	m.listings++
+	if m.offline {
+		return nil, errOffline
+	}
+	var documents []Document
+	for _, document := range m.documents {
+		if !document.Deleted {
+			listed := *document
+			listed.Body = ""
+			documents = append(documents, listed)
+		}
+	}
+	return documents, nil
+}
+
+func (m *memoryBackend) Get(id string) (Document, error) {
+	document, ok := m.documents[id]
+	if !ok {
+		return Document{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return *document, nil
+}
+
+func (m *memoryBackend) Create(entry parser.Entry) (Document, error) {
+	if m.offline {
+		return Document{}, errOffline
+	}
+	m.nextID++
+	id := fmt.Sprintf("doc-%d", m.nextID)
+	m.documents[id] = &Document{ID: id, Date: entry.Date}
+	return m.write(id, entry.Body), nil
+}
+
+func (m *memoryBackend) Update(id string, entry parser.Entry, previous state.EntryState) (Document, error) {
+	if m.offline {
+		return Document{}, errOffline
+	}
+	if document, ok := m.documents[id]; !ok || document.Deleted {
+		return Document{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return m.write(id, entry.Body), nil
+}
+
+func (m *memoryBackend) Delete(id string) error {
+	if _, ok := m.documents[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	m.documents[id].Deleted = true
+	return nil
+}
+
+func (m *memoryBackend) Changes(cursor string) (Changes, error) {
+	documents, err := m.List()
+	return Changes{Documents: documents, Complete: !m.incomplete}, err
+}
+
+func (m *memoryBackend) Normalize(body string) string { return body }
+
+// add stores a document directly, as if it was written remotely
+func (m *memoryBackend) add(date, body string) string {
+	m.nextID++
+	id := fmt.Sprintf("doc-%d", m.nextID)
+	m.documents[id] = &Document{ID: id, Date: date}
+	m.write(id, body)
+	return id
+}
+
+func (m *memoryBackend) write(id, body string) Document {
+	if !m.sameMinute {
+		m.now = m.now.Add(time.Minute)
+	}
+	m.documents[id].Body = body
+	m.documents[id].EditedAt = m.now
+	return *m.documents[id]
+}
+
+func chdirTemp(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	t.Cleanup(func() { os.Chdir(originalDir) })
+}
+
+func newState() *state.State {
+	return &state.State{Entries: make(map[string]state.EntryState)}
+}
+
+// runSync runs the steps of a two-way sync, in the order hfl sync does
+func runSync(t *testing.T, engine *Engine, journal *parser.Journal, syncState *state.State) {
+	t.Helper()
+	if _, err := engine.FindDuplicates(syncState); err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	if err := engine.AdoptExisting(journal, syncState); err != nil {
+		t.Fatalf("AdoptExisting failed: %v", err)
+	}
+	if _, err := engine.DetectConflicts(journal, syncState); err != nil {
+		t.Fatalf("DetectConflicts failed: %v", err)
+	}
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+}
+
+func TestPush_CreatesAndUpdates(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	engine := NewEngine(backend, Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "First."}}}
+	syncState := newState()
+
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	id := syncState.Entries["2025-08-16"].RemoteID
+	if id == "" || backend.documents[id].Body != "First." {
+		t.Fatalf("Expected the entry to be created, got state %+v", syncState.Entries["2025-08-16"])
+	}
+
+	journal.Entries[0].Body = "Second."
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if backend.documents[id].Body != "Second." {
+		t.Errorf("Expected the document to be updated, got %q", backend.documents[id].Body)
+	}
+
+	report := engine.Report()
+	if len(report) != 2 || report[0].Action != ActionCreated || report[1].Action != ActionUpdated {
+		t.Errorf("Expected created then updated, got %+v", report)
+	}
+}
+
+func TestPull_AppliesRemoteEdits(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	engine := NewEngine(backend, Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Local."}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	backend.write(syncState.Entries["2025-08-16"].RemoteID, "Remote.")
+	backend.add("2025-08-17", "New remotely.")
+
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	if len(journal.Entries) != 2 || journal.Entries[0].Body != "Remote." || journal.Entries[1].Body != "New remotely." {
+		t.Errorf("Expected both remote changes, got %+v", journal.Entries)
+	}
+	if syncState.HasChanged("2025-08-16", "Remote.") {
+		t.Error("Expected the pulled body to be recorded as synced")
+	}
+}
+
+func TestPull_AppliesEditsInTheMinuteOfThePush(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	backend.now = time.Now().UTC().Truncate(time.Second)
+	backend.sameMinute = true
+	engine := NewEngine(backend, Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Local."}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	// Edited remotely right after the push, so the edit time didn't move
+	backend.write(syncState.Entries["2025-08-16"].RemoteID, "Remote.")
+
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	if journal.Entries[0].Body != "Remote." {
+		t.Errorf("Expected the remote edit to be pulled, got %q", journal.Entries[0].Body)
+	}
+}
+
+func TestDetectConflicts_MergesBothSides(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	engine := NewEngine(backend, Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "One.\n\nTwo.\n\nThree."}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	backend.write(syncState.Entries["2025-08-16"].RemoteID, "One remotely.\n\nTwo.\n\nThree.")
+	journal.Entries[0].Body = "One.\n\nTwo.\n\nThree locally."
+
+	conflicts, err := engine.DetectConflicts(journal, syncState)
+	if err != nil {
+		t.Fatalf("DetectConflicts failed: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Date != "2025-08-16" {
+		t.Fatalf("Expected one conflict, got %+v", conflicts)
+	}
+
+	unresolved, err := engine.MergeConflicts(journal, syncState, conflicts)
+	if err != nil {
+		t.Fatalf("MergeConflicts failed: %v", err)
+	}
+	if len(unresolved) != 0 {
+		t.Fatalf("Expected a clean merge, got %v", unresolved)
+	}
+
+	if expected := "One remotely.\n\nTwo.\n\nThree locally."; journal.Entries[0].Body != expected {
+		t.Errorf("Expected %q, got %q", expected, journal.Entries[0].Body)
+	}
+}
+
+func TestPull_UnlinksRemoteDeletes(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	engine := NewEngine(backend, Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Kept."}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	backend.Delete(syncState.Entries["2025-08-16"].RemoteID)
+
+	// A partial listing says nothing about what's missing from it
+	backend.incomplete = true
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if syncState.Entries["2025-08-16"].Unlinked {
+		t.Fatal("Expected no remote delete from an incomplete listing")
+	}
+
+	backend.incomplete = false
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if !syncState.Entries["2025-08-16"].Unlinked || len(journal.Entries) != 1 {
+		t.Errorf("Expected the entry to be kept and unlinked, got %+v", syncState.Entries["2025-08-16"])
+	}
+}
+
+func TestAdoptExisting_FlagsDifferences(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	engine := NewEngine(backend, Options{})
+
+	same := backend.add("2025-08-15", "Same.")
+	backend.add("2025-08-16", "Remote.")
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-15", Body: "Same."},
+		{Date: "2025-08-16", Body: "Local."},
+	}}
+	syncState := newState()
+
+	if err := engine.AdoptExisting(journal, syncState); err != nil {
+		t.Fatalf("AdoptExisting failed: %v", err)
+	}
+
+	if entry := syncState.Entries["2025-08-15"]; entry.RemoteID != same || entry.Conflict {
+		t.Errorf("Expected a clean link to %s, got %+v", same, entry)
+	}
+	if !syncState.Entries["2025-08-16"].Conflict {
+		t.Error("Expected a differing document to be flagged as a conflict")
+	}
+}
+
+func TestPush_QueuesWhileOfflineAndDrains(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	engine := NewEngine(backend, Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-15", Body: "Synced."}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	backend.offline = true
+	journal.Entries[0].Body = "Edited offline."
+	journal.Entries = append(journal.Entries, parser.Entry{Date: "2025-08-16", Body: "Written offline."})
+
+	err := engine.Push(journal, syncState)
+	if !Unreachable(err) {
+		t.Fatalf("Expected an unreachable backend, got %v", err)
+	}
+	if len(syncState.Queue) != 2 || syncState.Queue[0].Op != state.OpUpdate || syncState.Queue[1].Op != state.OpCreate {
+		t.Fatalf("Expected an update then a create, got %+v", syncState.Queue)
+	}
+
+	// Edited again before the next sync, which still pushes it once
+	journal.Entries[0].Body = "Edited offline twice."
+	backend.offline = false
+
+	engine = NewEngine(backend, Options{})
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if len(syncState.Queue) != 0 {
+		t.Errorf("Expected the queue to be drained, got %+v", syncState.Queue)
+	}
+	if body := backend.documents[syncState.Entries["2025-08-15"].RemoteID].Body; body != "Edited offline twice." {
+		t.Errorf("Expected the latest body to be pushed, got %q", body)
+	}
+
+	report := engine.Report()
+	if len(report) != 2 || report[0].Date != "2025-08-15" || report[1].Action != ActionCreated {
+		t.Errorf("Expected the queue to be pushed in order, got %+v", report)
+	}
+}
+
+func TestFindDuplicates_IndexKeptForTheRun(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	backend.add("2025-08-14", "Remote.")
+	backend.add("2025-08-15", "Remote.")
+	backend.add("2025-08-15", "Remote again.")
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-14", Body: "Remote."},
+		{Date: "2025-08-16", Body: "New locally."},
+	}}
+	syncState := newState()
+	engine := NewEngine(backend, Options{})
+
+	duplicates, err := engine.FindDuplicates(syncState)
+	if err != nil {
+		t.Fatalf("FindDuplicates failed: %v", err)
+	}
+	if len(duplicates) != 1 || duplicates[0].Date != "2025-08-15" || len(duplicates[0].IDs) != 2 {
+		t.Fatalf("Expected 2025-08-15 to have two documents, got %+v", duplicates)
+	}
+
+	if err := engine.AdoptExisting(journal, syncState); err != nil {
+		t.Fatalf("AdoptExisting failed: %v", err)
+	}
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+
+	if backend.listings != 1 {
+		t.Errorf("Expected the duplicate check's listing to be reused, got %d listings", backend.listings)
+	}
+	if syncState.Entries["2025-08-14"].RemoteID == "" || syncState.Entries["2025-08-16"].RemoteID == "" {
+		t.Errorf("Expected both entries to be linked, got %+v", syncState.Entries)
+	}
+}
+
+func TestSync_FinishesInterruptedCreate(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+
+	// The document was created, but the sync died before the rest of
+	// the body was appended and its ID saved
+	backend.add("2025-08-16", "partial")
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "partial and the rest of a long entry"}}}
+	syncState := newState()
+	syncState.MarkPendingCreate("2025-08-16")
+
+	engine := NewEngine(backend, Options{})
+	runSync(t, engine, journal, syncState)
+
+	if journal.Entries[0].Body != "partial and the rest of a long entry" {
+		t.Fatalf("Expected hfl.md to keep the full body, got %q", journal.Entries[0].Body)
+	}
+
+	entryState := syncState.Entries["2025-08-16"]
+	if entryState.Conflict || entryState.PendingCreate != "" || entryState.RemoteID != "doc-1" {
+		t.Errorf("Expected the document to be linked without a conflict, got %+v", entryState)
+	}
+	if body := backend.documents["doc-1"].Body; body != "partial and the rest of a long entry" {
+		t.Errorf("Expected the full body to be pushed, got %q", body)
+	}
+	if report := engine.Report(); len(report) != 1 || report[0].Action != ActionRecovered {
+		t.Errorf("Expected the document to be recovered, got %+v", report)
+	}
+}
+
+func TestSync_ListsOncePerRun(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	backend.add("2025-08-14", "Remote.")
+
+	journal := &parser.Journal{Entries: []parser.Entry{
+		{Date: "2025-08-15", Body: "Local."},
+		{Date: "2025-08-16", Body: "New locally."},
+	}}
+	syncState := newState()
+
+	runSync(t, NewEngine(backend, Options{}), journal, syncState)
+	if backend.listings != 1 {
+		t.Errorf("Expected one listing for a full sync, got %d", backend.listings)
+	}
+	if len(journal.Entries) != 3 || len(backend.documents) != 3 {
+		t.Fatalf("Expected both sides to have every entry, got %+v", journal.Entries)
+	}
+
+	backend.listings = 0
+	backend.incomplete = true
+	journal.Entries = append(journal.Entries, parser.Entry{Date: "2025-08-17", Body: "Newer."})
+
+	// A new entry needs the whole backend to look for an existing document
+	runSync(t, NewEngine(backend, Options{}), journal, syncState)
+	if backend.listings != 2 {
+		t.Errorf("Expected a partial listing and a full one, got %d", backend.listings)
+	}
+}
+
+func TestPull_PartialListingSkipsOtherDocuments(t *testing.T) {
+	chdirTemp(t)
+	backend := newMemoryBackend()
+	engine := NewEngine(backend, Options{})
+
+	journal := &parser.Journal{Entries: []parser.Entry{{Date: "2025-08-16", Body: "Linked."}}}
+	syncState := newState()
+	if err := engine.Push(journal, syncState); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	linked := syncState.Entries["2025-08-16"].RemoteID
+
+	backend.add("2025-08-16", "Duplicate.")
+	backend.incomplete = true
+
+	engine = NewEngine(backend, Options{})
+	if err := engine.Pull(journal, syncState); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+
+	if journal.Entries[0].Body != "Linked." || syncState.Entries["2025-08-16"].RemoteID != linked {
+		t.Errorf("Expected the linked document to be kept, got %q linked to %s", journal.Entries[0].Body, syncState.Entries["2025-08-16"].RemoteID)
+	}
+	if report := engine.Report(); len(report) != 1 || report[0].Action != ActionSkipped {
+		t.Errorf("Expected the other document to be skipped, got %+v", report)
+	}
+}
@@ -0,0 +1,54 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+)
+
+// Report actions
+const (
+	ActionCreated   = "created"
+	ActionRecovered = "recovered"
+	ActionLinked    = "linked"
+	ActionUpdated   = "updated"
+	ActionPulled    = "pulled"
+	ActionArchived  = "archived"
+	ActionMerged    = "merged"
+	ActionDeleted   = "deleted"
+	ActionRecreate  = "recreate"
+	ActionUnlinked  = "unlinked"
+	ActionSkipped   = "skipped"
+)
+
+// ReportItem is one decision taken for an entry during a sync
+type ReportItem struct {
+	Date   string
+	Action string
+	Detail string
+}
+
+// Report returns the decisions taken so far, in the order they were made
+func (e *Engine) Report() []ReportItem {
+	return e.report
+}
+
+func (e *Engine) record(date, action, detail string) {
+	e.report = append(e.report, ReportItem{Date: date, Action: action, Detail: detail})
+}
+
+// WriteReport prints a sync report, one line per decision
+func WriteReport(w io.Writer, items []ReportItem) {
+	if len(items) == 0 {
+		fmt.Fprintln(w, "Sync report: no changes")
+		return
+	}
+
+	fmt.Fprintf(w, "Sync report (%d):\n", len(items))
+	for _, item := range items {
+		if item.Detail == "" {
+			fmt.Fprintf(w, "  %s  %s\n", item.Date, item.Action)
+		} else {
+			fmt.Fprintf(w, "  %s  %-9s %s\n", item.Date, item.Action, item.Detail)
+		}
+	}
+}